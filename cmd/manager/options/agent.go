@@ -7,6 +7,7 @@ import (
 	"github.com/choerodon/choerodon-cluster-agent/controller"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
 	agentnamespace "github.com/choerodon/choerodon-cluster-agent/pkg/agent/namespace"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/outbox"
 	apis "github.com/choerodon/choerodon-cluster-agent/pkg/apis/choerodon"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kubectl"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kubernetes"
@@ -19,6 +20,7 @@ import (
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/recorder"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/version"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
 	"github.com/golang/glog"
@@ -54,7 +56,7 @@ const (
 
 type AgentOptions struct {
 	Listen       string
-	UpstreamURL  string
+	UpstreamURLs []string
 	Token        string
 	PrintVersion bool
 	// kubernetes controller
@@ -85,6 +87,42 @@ type AgentOptions struct {
 	kubernetesKubectl  string
 	statusSyncInterval time.Duration
 	syncAll            bool
+	// response outbox
+	outboxDir       string
+	outboxSize      int
+	outboxRetention map[string]string
+	ackResponses    bool
+	// websocket framing
+	wsCompression  bool
+	maxPayloadSize int
+	// websocket heartbeat and reconnect
+	wsPingInterval      time.Duration
+	wsPongWait          time.Duration
+	reconnectBackOff    time.Duration
+	maxReconnectBackOff time.Duration
+	failoverAfter       int
+	// exec and log pipes
+	maxPipes        int
+	pipeIdleTimeout time.Duration
+	// upstream TLS
+	upstreamTLS websocket.TLSOptions
+	// token file
+	tokenFile             string
+	tokenFilePollInterval time.Duration
+	// command workers
+	commandWorkers   int
+	commandQueueSize int
+	commandTimeout   time.Duration
+	commandTimeouts  map[string]string
+	// graceful shutdown
+	shutdownGracePeriod time.Duration
+	// release rollout tracking
+	releaseRolloutTimeout time.Duration
+	// exec session recording
+	recordingDir      string
+	recordingMaxAge   time.Duration
+	recordingMaxCount int
+	recordingMaxSize  int64
 }
 
 var log = logf.Log.WithName("cmd")
@@ -258,19 +296,43 @@ func Run(o *AgentOptions, f cmdutil.Factory) {
 		errChan <- fmt.Errorf("%s", <-c)
 	}()
 
-	// graceful shutdown
-	defer func() {
-		glog.Errorf("exiting %s", <-errChan)
-		close(shutdown)
-		shutdownWg.Wait()
-	}()
+	respOutbox, err := newOutbox(o)
+	if err != nil {
+		log.Error(err, "Failed to open response outbox")
+		os.Exit(1)
+	}
 
-	appClient, err := websocket.NewClient(websocket.Token(o.Token), o.UpstreamURL, crChan)
+	token := websocket.Token(o.Token)
+	if o.tokenFile != "" {
+		token, err = websocket.ReadTokenFile(o.tokenFile)
+		if err != nil {
+			log.Error(err, "Failed to read token file")
+			os.Exit(1)
+		}
+	}
+
+	appClient, err := websocket.NewClient(token, o.UpstreamURLs, crChan, &websocket.Options{
+		Outbox:              respOutbox,
+		AckResponses:        o.ackResponses,
+		Compression:         o.wsCompression,
+		MaxPayloadSize:      o.maxPayloadSize,
+		PingInterval:        o.wsPingInterval,
+		PongWait:            o.wsPongWait,
+		ReconnectBackOff:    o.reconnectBackOff,
+		MaxReconnectBackOff: o.maxReconnectBackOff,
+		FailoverAfter:       o.failoverAfter,
+		MaxPipes:            o.maxPipes,
+		PipeIdleTimeout:     o.pipeIdleTimeout,
+		TLS:                 &o.upstreamTLS,
+	})
 	if err != nil {
-		errChan <- err
-		return
+		log.Error(err, "Failed to create DevOps service client")
+		os.Exit(1)
 	}
 	go appClient.Loop(shutdown, shutdownWg)
+	if o.tokenFile != "" {
+		go websocket.WatchTokenFile(o.tokenFile, o.tokenFilePollInterval, appClient, shutdown)
+	}
 
 	//gitRemote := git.Remote{URL: o.gitURL}
 	gitConfig := git.Config{
@@ -315,6 +377,25 @@ func Run(o *AgentOptions, f cmdutil.Factory) {
 
 		k8s = kubernetes.NewCluster(kubeClient.GetKubeClient(), mgr, kubectlApplier)
 	}
+	commandTimeouts, err := agent.ParseCommandTimeouts(o.commandTimeouts)
+	if err != nil {
+		log.Error(err, "Failed to parse command timeouts")
+		os.Exit(1)
+	}
+
+	var execRecorder *recorder.Recorder
+	if o.recordingDir != "" {
+		execRecorder, err = recorder.New(o.recordingDir, recorder.Options{
+			MaxAge:   o.recordingMaxAge,
+			MaxCount: o.recordingMaxCount,
+			MaxSize:  o.recordingMaxSize,
+		})
+		if err != nil {
+			log.Error(err, "Failed to open exec recording dir")
+			os.Exit(1)
+		}
+	}
+
 	workerManager := agent.NewWorkerManager(
 		crChan,
 		kubeClient,
@@ -329,14 +410,33 @@ func Run(o *AgentOptions, f cmdutil.Factory) {
 		ctx2,
 		shutdownWg,
 		shutdown,
-		o.Token,
 		o.PlatformCode,
 		o.syncAll,
+		o.commandWorkers,
+		o.commandQueueSize,
+		o.commandTimeout,
+		commandTimeouts,
+		execRecorder,
+		o.releaseRolloutTimeout,
 	)
 
 	go workerManager.Start()
 	shutdownWg.Add(1)
 
+	// graceful shutdown: stop taking commands, let the running ones finish and
+	// hand their responses to DevOps service before everything is stopped
+	defer func() {
+		glog.Errorf("exiting %s", <-errChan)
+		ctx, cancel := context.WithTimeout(context.Background(), o.shutdownGracePeriod)
+		defer cancel()
+		if err := workerManager.Drain(ctx); err != nil {
+			glog.Errorf("drain commands: %v", err)
+		}
+		appClient.Flush(ctx)
+		close(shutdown)
+		shutdownWg.Wait()
+	}()
+
 	go func() {
 		errChan <- http.ListenAndServe(o.Listen, nil)
 	}()
@@ -348,8 +448,10 @@ func (o *AgentOptions) BindFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Listen, "listen", o.Listen, "address:port to listen on")
 	fs.StringVar(&kube.AgentVersion, "agent-version", "", "agent version")
 	// upstream
-	fs.StringVar(&o.UpstreamURL, "connect", "", "Connect to an upstream service")
+	fs.StringSliceVar(&o.UpstreamURLs, "connect", nil, "Connect to an upstream service, comma separated or repeated to give failover endpoints in order of preference")
 	fs.StringVar(&o.Token, "token", "", "Authentication token for upstream service")
+	fs.StringVar(&o.tokenFile, "token-file", "", "Optional, file to read the authentication token from instead of --token, such as a mounted Secret, the agent reconnects when it changes")
+	fs.DurationVar(&o.tokenFilePollInterval, "token-file-poll-interval", 10*time.Second, "how often to check the token file for changes")
 	fs.Int32Var(&o.ClusterId, "clusterId", 0, "the env cluster id in devops")
 
 	// kubernetes controller
@@ -379,6 +481,62 @@ func (o *AgentOptions) BindFlags(fs *pflag.FlagSet) {
 	fs.DurationVar(&o.gitTimeOut, "git-timeout", 1*time.Minute, "git time out")
 	fs.StringVar(&o.kubernetesKubectl, "kubernetes-kubectl", "", "Optional, explicit path to kubectl tool")
 	fs.BoolVar(&o.syncAll, "sync-all", false, "sync all or change")
+	// command workers
+	fs.IntVar(&o.commandWorkers, "command-workers", agent.DefaultCommandWorkers, "The number of commands that are allowed to run concurrently, commands of the same release always run one after another")
+	fs.IntVar(&o.commandQueueSize, "command-queue-size", agent.DefaultCommandQueueSize, "The number of commands each worker can queue before receiving commands blocks")
+	fs.DurationVar(&o.commandTimeout, "command-timeout", agent.DefaultCommandTimeout, "time after which a command is aborted")
+	fs.StringToStringVar(&o.commandTimeouts, "command-timeouts", map[string]string{
+		model.HelmInstallRelease: "20m",
+		model.HelmReleaseUpgrade: "20m",
+		model.ExecuteTest:        "20m",
+	}, "timeout per command type overriding command-timeout, as type=duration")
+	fs.DurationVar(&o.releaseRolloutTimeout, "release-rollout-timeout", 10*time.Minute, "how long to wait after an install or upgrade for the release workloads to be ready before reporting the rollout failed, 0 does not report readiness")
+	fs.DurationVar(&o.shutdownGracePeriod, "shutdown-grace-period", 20*time.Second, "how long to wait on shutdown for running commands to finish and their responses to be sent, keep it below the pod termination grace period")
+	// response outbox
+	fs.StringVar(&o.outboxDir, "outbox-dir", "", "Optional, directory to journal undelivered responses in, responses are only kept in memory if empty")
+	fs.IntVar(&o.outboxSize, "outbox-size", outbox.DefaultMaxSize, "max number of undelivered responses to keep, the oldest are dropped first")
+	fs.StringToStringVar(&o.outboxRetention, "outbox-retention", map[string]string{model.StatusSyncEvent: outbox.PolicyDiscard}, "retention of undelivered responses per type, as type=duration or type=discard")
+	fs.BoolVar(&o.ackResponses, "ack-responses", false, "keep responses in the outbox until DevOps service acknowledges them")
+	// websocket framing
+	fs.BoolVar(&o.wsCompression, "websocket-compression", false, "negotiate permessage-deflate compression with DevOps service")
+	fs.IntVar(&o.maxPayloadSize, "max-payload-size", 0, "split response payloads larger than this many bytes into numbered fragments, 0 disables splitting")
+	// websocket heartbeat and reconnect
+	fs.DurationVar(&o.wsPingInterval, "websocket-ping-interval", 30*time.Second, "how often to ping DevOps service, 0 disables pings")
+	fs.DurationVar(&o.wsPongWait, "websocket-pong-wait", 0, "drop the connection when nothing is read from DevOps service for this long, defaults to twice the ping interval")
+	fs.DurationVar(&o.reconnectBackOff, "reconnect-backoff", 1*time.Second, "initial delay before reconnecting to DevOps service, doubled after every failed attempt")
+	fs.DurationVar(&o.maxReconnectBackOff, "max-reconnect-backoff", 60*time.Second, "max delay before reconnecting to DevOps service")
+	fs.IntVar(&o.failoverAfter, "failover-after", websocket.DefaultFailoverAfter, "dial errors in a row after which the next --connect endpoint is tried")
+	// exec and log pipes
+	fs.IntVar(&o.maxPipes, "max-pipes", 100, "max number of exec and log pipes open at once, 0 is unlimited")
+	fs.DurationVar(&o.pipeIdleTimeout, "pipe-idle-timeout", 30*time.Minute, "close exec and log pipes nothing went through for this long, 0 keeps them open")
+	fs.Int64Var(&kube.CopyMaxBytes, "copy-max-bytes", kube.CopyMaxBytes, "max size of the tar archives copied into and out of containers")
+	// exec session recording
+	fs.StringVar(&o.recordingDir, "exec-recording-dir", "", "Optional, directory to record exec sessions in as asciinema cast files, sessions are not recorded if empty")
+	fs.DurationVar(&o.recordingMaxAge, "exec-recording-max-age", 30*24*time.Hour, "remove exec recordings older than this, 0 keeps them")
+	fs.IntVar(&o.recordingMaxCount, "exec-recording-max-count", 1000, "max number of exec recordings to keep, the oldest are removed first, 0 is unlimited")
+	fs.Int64Var(&o.recordingMaxSize, "exec-recording-max-size", 10*1024*1024, "stop recording an exec session once its recording reaches this many bytes, 0 is unlimited")
+	// upstream TLS
+	fs.StringVar(&o.upstreamTLS.CAFile, "upstream-ca-file", "", "Optional, PEM bundle of the CAs trusted to sign the DevOps service certificate, the system roots are used if empty")
+	fs.StringVar(&o.upstreamTLS.CertFile, "upstream-cert-file", "", "Optional, client certificate presented to DevOps service")
+	fs.StringVar(&o.upstreamTLS.KeyFile, "upstream-key-file", "", "Optional, key of the client certificate presented to DevOps service")
+	fs.StringVar(&o.upstreamTLS.ServerName, "upstream-server-name", "", "Optional, name to verify the DevOps service certificate against instead of the host of the connect URL")
+	fs.BoolVar(&o.upstreamTLS.InsecureSkipVerify, "upstream-insecure-skip-verify", false, "do not verify the DevOps service certificate, only for lab installs")
+}
+
+func newOutbox(o *AgentOptions) (outbox.Outbox, error) {
+	policies, err := outbox.ParsePolicies(o.outboxRetention)
+	if err != nil {
+		return nil, err
+	}
+	opts := outbox.Options{
+		MaxSize:  o.outboxSize,
+		Policies: policies,
+	}
+	if o.outboxDir == "" {
+		return outbox.NewMemory(opts), nil
+	}
+	glog.Infof("journal undelivered responses in %s", o.outboxDir)
+	return outbox.NewFile(o.outboxDir, opts)
 }
 
 func checkKube(client *k8sclient.Clientset) {
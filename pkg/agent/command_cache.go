@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+const (
+	commandCacheSize = 1000
+	commandCacheTTL  = 30 * time.Minute
+)
+
+// commandCache remembers the results of the latest commands, so that a
+// command re-sent by DevOps service after a reconnect returns the previous
+// result instead of running the Helm or Kubernetes operation again.
+type commandCache struct {
+	mtx     sync.Mutex
+	size    int
+	ttl     time.Duration
+	keys    []commandKey
+	entries map[commandKey]*commandEntry
+}
+
+// commandKey identifies a command. The seq alone is only unique on one
+// connection, so it is qualified by the key and type of the command.
+type commandKey struct {
+	key     string
+	cmdType string
+	seq     uint64
+}
+
+type commandEntry struct {
+	done    bool
+	resp    *model.Packet
+	expires time.Time
+}
+
+func newCommandCache(size int, ttl time.Duration) *commandCache {
+	return &commandCache{
+		size:    size,
+		ttl:     ttl,
+		keys:    make([]commandKey, 0, size),
+		entries: make(map[commandKey]*commandEntry, size),
+	}
+}
+
+func keyOf(cmd *model.Packet) commandKey {
+	return commandKey{key: cmd.Key, cmdType: cmd.Type, seq: cmd.Seq}
+}
+
+// Start records that cmd is about to run. If the command was already seen
+// it returns false, along with whether it has finished and the response it
+// produced.
+func (c *commandCache) Start(cmd *model.Packet) (started bool, done bool, resp *model.Packet) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	k := keyOf(cmd)
+	if entry, ok := c.entries[k]; ok {
+		if !entry.done || time.Now().Before(entry.expires) {
+			return false, entry.done, entry.resp
+		}
+		c.remove(k)
+	}
+	if len(c.keys) >= c.size {
+		c.evict()
+	}
+	c.keys = append(c.keys, k)
+	c.entries[k] = &commandEntry{}
+	return true, false, nil
+}
+
+// Finish stores the response of cmd, resp may be nil for commands which
+// only start a stream. Failures are forgotten so that a retry runs again.
+func (c *commandCache) Finish(cmd *model.Packet, resp *model.Packet) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	k := keyOf(cmd)
+	entry, ok := c.entries[k]
+	if !ok {
+		return
+	}
+	if isFailure(resp) {
+		c.remove(k)
+		return
+	}
+	entry.done = true
+	entry.resp = resp
+	entry.expires = time.Now().Add(c.ttl)
+}
+
+// evict drops the oldest finished command. Commands still running are
+// kept, so the cache grows past its size while all of them are in flight.
+func (c *commandCache) evict() {
+	for i, k := range c.keys {
+		if c.entries[k].done {
+			delete(c.entries, k)
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *commandCache) remove(k commandKey) {
+	delete(c.entries, k)
+	for i, key := range c.keys {
+		if key == k {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// isFailure tells the responses built by command.NewResponseError, their
+// types end with failed.
+func isFailure(resp *model.Packet) bool {
+	return resp != nil && strings.HasSuffix(resp.Type, "failed")
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+func TestCommandCache(t *testing.T) {
+	cache := newCommandCache(2, time.Minute)
+	cmd := &model.Packet{Key: "env:test.release:app", Type: model.HelmInstallRelease, Seq: 1}
+
+	started, _, _ := cache.Start(cmd)
+	assert.True(t, started, "new command not started")
+	started, done, _ := cache.Start(cmd)
+	assert.False(t, started, "running command started twice")
+	assert.False(t, done, "running command done")
+
+	resp := &model.Packet{Key: "env:test", Type: model.HelmInstallRelease}
+	cache.Finish(cmd, resp)
+	started, done, cached := cache.Start(cmd)
+	assert.False(t, started, "finished command started twice")
+	assert.True(t, done, "finished command not done")
+	assert.Equal(t, resp, cached, "bad cached response")
+
+	// the same seq on another connection is another command
+	other := &model.Packet{Key: "env:test.release:other", Type: model.HelmInstallRelease, Seq: 1}
+	started, _, _ = cache.Start(other)
+	assert.True(t, started, "command with the same seq and another key not started")
+
+	cache.Start(&model.Packet{Key: "env:test", Type: model.HelmInstallRelease, Seq: 3})
+	started, _, _ = cache.Start(cmd)
+	assert.True(t, started, "oldest command not evicted")
+}
+
+func TestCommandCacheExpire(t *testing.T) {
+	cache := newCommandCache(10, time.Nanosecond)
+	cmd := &model.Packet{Key: "env:test", Type: model.HelmInstallRelease, Seq: 1}
+	cache.Start(cmd)
+	cache.Finish(cmd, nil)
+	time.Sleep(time.Millisecond)
+	started, _, _ := cache.Start(cmd)
+	assert.True(t, started, "expired command not started again")
+}
+
+func TestCommandCacheFailure(t *testing.T) {
+	cache := newCommandCache(10, time.Minute)
+	cmd := &model.Packet{Key: "env:test", Type: model.HelmInstallRelease, Seq: 1}
+	cache.Start(cmd)
+	cache.Finish(cmd, &model.Packet{Key: cmd.Key, Type: model.HelmReleaseInstallFailed})
+	started, _, _ := cache.Start(cmd)
+	assert.True(t, started, "failed command not started again")
+}
+
+func TestCommandCacheEvictRunning(t *testing.T) {
+	cache := newCommandCache(2, time.Minute)
+	running := &model.Packet{Key: "env:test.release:a", Type: model.HelmInstallRelease, Seq: 1}
+	finished := &model.Packet{Key: "env:test.release:b", Type: model.HelmInstallRelease, Seq: 2}
+	cache.Start(running)
+	cache.Start(finished)
+	cache.Finish(finished, nil)
+
+	// the finished command goes first although the running one is older
+	cache.Start(&model.Packet{Key: "env:test.release:c", Type: model.HelmInstallRelease, Seq: 3})
+	started, _, _ := cache.Start(running)
+	assert.False(t, started, "running command evicted")
+	started, _, _ = cache.Start(finished)
+	assert.True(t, started, "finished command not evicted")
+
+	// with every command running the cache grows
+	started, _, _ = cache.Start(running)
+	assert.False(t, started, "running command evicted from a full cache")
+}
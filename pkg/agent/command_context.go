@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+const DefaultCommandTimeout = 5 * time.Minute
+
+// commandContexts keeps a context for every queued or running command, so
+// that DevOps service can abort it with a cancel_command.
+type commandContexts struct {
+	mtx      sync.Mutex
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+	entries  map[*model.Packet]*commandContext
+}
+
+type commandContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newCommandContexts(timeout time.Duration, timeouts map[string]time.Duration) *commandContexts {
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	return &commandContexts{
+		timeout:  timeout,
+		timeouts: timeouts,
+		entries:  map[*model.Packet]*commandContext{},
+	}
+}
+
+// Add creates the context of a command when it is queued, so that it can
+// be cancelled before it starts.
+func (c *commandContexts) Add(cmd *model.Packet) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.entries[cmd] = &commandContext{ctx: ctx, cancel: cancel}
+}
+
+// Remove drops the context of a command that will not run.
+func (c *commandContexts) Remove(cmd *model.Packet) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if entry, ok := c.entries[cmd]; ok {
+		entry.cancel()
+		delete(c.entries, cmd)
+	}
+}
+
+// Start returns the context to run a command with, bounded by the timeout of
+// its type. The returned cancel func must be called once the command is done.
+func (c *commandContexts) Start(cmd *model.Packet) (context.Context, context.CancelFunc) {
+	c.mtx.Lock()
+	entry, ok := c.entries[cmd]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		entry = &commandContext{ctx: ctx, cancel: cancel}
+		c.entries[cmd] = entry
+	}
+	c.mtx.Unlock()
+
+	timeout, ok := c.timeouts[cmd.Type]
+	if !ok {
+		timeout = c.timeout
+	}
+	ctx, cancel := context.WithTimeout(entry.ctx, timeout)
+	return ctx, func() {
+		cancel()
+		entry.cancel()
+		c.mtx.Lock()
+		delete(c.entries, cmd)
+		c.mtx.Unlock()
+	}
+}
+
+// Cancel aborts the commands matching req and returns how many there were.
+func (c *commandContexts) Cancel(req *model.CancelCommandRequest) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	n := 0
+	for cmd, entry := range c.entries {
+		if req.Seq > 0 && cmd.Seq != req.Seq {
+			continue
+		}
+		if req.Seq == 0 && cmd.Key != req.Key {
+			continue
+		}
+		entry.cancel()
+		n++
+	}
+	return n
+}
+
+// CancelAll aborts every queued or running command.
+func (c *commandContexts) CancelAll() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, entry := range c.entries {
+		entry.cancel()
+	}
+	return len(c.entries)
+}
+
+// ParseCommandTimeouts converts type=duration pairs into command timeouts.
+func ParseCommandTimeouts(values map[string]string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(values))
+	for cmdType, value := range values {
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("parse timeout of %s: %v", cmdType, err)
+		}
+		timeouts[cmdType] = timeout
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+func TestCommandContextsCancel(t *testing.T) {
+	contexts := newCommandContexts(time.Minute, map[string]time.Duration{
+		model.HelmInstallRelease: time.Hour,
+	})
+	install := &model.Packet{Key: "env:test.release:app", Type: model.HelmInstallRelease, Seq: 1}
+	upgrade := &model.Packet{Key: "env:test.release:app", Type: model.HelmReleaseUpgrade, Seq: 2}
+	contexts.Add(install)
+	contexts.Add(upgrade)
+
+	ctx, done := contexts.Start(install)
+	deadline, _ := ctx.Deadline()
+	assert.True(t, time.Until(deadline) > time.Minute, "type timeout not applied")
+
+	assert.Equal(t, 1, contexts.Cancel(&model.CancelCommandRequest{Seq: 2}), "bad cancelled count")
+	assert.Nil(t, ctx.Err(), "other command cancelled")
+	upgradeCtx, upgradeDone := contexts.Start(upgrade)
+	assert.Equal(t, context.Canceled, upgradeCtx.Err(), "queued command not cancelled")
+	upgradeDone()
+
+	assert.Equal(t, 1, contexts.Cancel(&model.CancelCommandRequest{Key: "env:test.release:app"}), "bad cancelled count")
+	assert.Equal(t, context.Canceled, ctx.Err(), "running command not cancelled")
+	done()
+	assert.Equal(t, 0, contexts.Cancel(&model.CancelCommandRequest{Seq: 1}), "finished command kept")
+}
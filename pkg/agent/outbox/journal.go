@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const (
+	recordSuffix = ".json"
+	// seqFilename holds the last sequence number handed out, records may
+	// all be replayed and removed before a restart.
+	seqFilename = "seq"
+)
+
+// NewMemory returns an outbox that only lives as long as the process.
+func NewMemory(opts Options) Outbox {
+	o, _ := newOutbox(&memoryJournal{}, opts)
+	return o
+}
+
+// NewFile returns an outbox journaled in dir, one file per record, so that
+// undelivered responses survive agent restarts.
+func NewFile(dir string, opts Options) (Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create outbox dir %s: %v", dir, err)
+	}
+	return newOutbox(&fileJournal{dir: dir}, opts)
+}
+
+type memoryJournal struct{}
+
+func (j *memoryJournal) load() ([]*Record, uint64, error) {
+	return nil, 0, nil
+}
+
+func (j *memoryJournal) write(record *Record) error {
+	return nil
+}
+
+func (j *memoryJournal) delete(seq uint64) error {
+	return nil
+}
+
+type fileJournal struct {
+	dir string
+}
+
+func (j *fileJournal) load() ([]*Record, uint64, error) {
+	seq, err := j.loadSeq()
+	if err != nil {
+		return nil, 0, err
+	}
+	files, err := ioutil.ReadDir(j.dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read outbox dir %s: %v", j.dir, err)
+	}
+	records := make([]*Record, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), recordSuffix) {
+			continue
+		}
+		filename := filepath.Join(j.dir, file.Name())
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read outbox record %s: %v", filename, err)
+		}
+		record := &Record{}
+		if err := json.Unmarshal(content, record); err != nil || record.Packet == nil {
+			// a record half written before a crash, nothing to replay
+			glog.Warningf("skip broken outbox record %s: %v", filename, err)
+			os.Remove(filename)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, seq, nil
+}
+
+func (j *fileJournal) loadSeq() (uint64, error) {
+	filename := filepath.Join(j.dir, seqFilename)
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read outbox seq %s: %v", filename, err)
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		// the records still give a lower bound
+		glog.Warningf("skip broken outbox seq %s: %v", filename, err)
+		return 0, nil
+	}
+	return seq, nil
+}
+
+// write stores the sequence number before the record, so the number is
+// never behind the records on disk.
+func (j *fileJournal) write(record *Record) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	seq := []byte(strconv.FormatUint(record.Seq, 10))
+	if err := j.writeFile(filepath.Join(j.dir, seqFilename), seq); err != nil {
+		return err
+	}
+	return j.writeFile(j.filename(record.Seq), content)
+}
+
+// writeFile replaces filename with content atomically.
+func (j *fileJournal) writeFile(filename string, content []byte) error {
+	tmp, err := ioutil.TempFile(j.dir, ".record")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (j *fileJournal) delete(seq uint64) error {
+	err := os.Remove(j.filename(seq))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (j *fileJournal) filename(seq uint64) string {
+	// zero padded so that the directory listing is in sequence order
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", seq, recordSuffix))
+}
@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+const (
+	DefaultMaxSize = 1000
+	// PolicyDiscard is the retention value that stops a type from being kept.
+	PolicyDiscard = "discard"
+)
+
+// Outbox keeps responses that could not be delivered to DevOps service,
+// so that they can be replayed in order once the connection is back.
+type Outbox interface {
+	// Put stores a packet and returns the sequence number assigned to it,
+	// 0 means the packet was not kept because of its retention policy.
+	Put(packet *model.Packet) (uint64, error)
+	// List returns all retained records ordered by sequence number.
+	List() ([]*Record, error)
+	Get(seq uint64) (*Record, bool)
+	Remove(seq uint64) error
+	Len() int
+}
+
+type Record struct {
+	Seq     uint64        `json:"seq"`
+	Created time.Time     `json:"created"`
+	Packet  *model.Packet `json:"packet"`
+}
+
+// Policy describes how long records of one packet type are retained.
+type Policy struct {
+	// MaxAge drops records older than it, zero keeps them until replayed.
+	MaxAge  time.Duration
+	Discard bool
+}
+
+type Options struct {
+	// MaxSize caps the number of records, the oldest ones are evicted first.
+	MaxSize  int
+	Policies map[string]Policy
+}
+
+// journal is the storage backend of an outbox.
+type journal interface {
+	// load returns the stored records and the last sequence number handed
+	// out, which outlives the records.
+	load() ([]*Record, uint64, error)
+	write(record *Record) error
+	delete(seq uint64) error
+}
+
+type outbox struct {
+	mtx     sync.Mutex
+	journal journal
+	opts    Options
+	seq     uint64
+	records []*Record
+}
+
+func newOutbox(j journal, opts Options) (*outbox, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	records, seq, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Seq < records[j].Seq
+	})
+	o := &outbox{
+		journal: j,
+		opts:    opts,
+		seq:     seq,
+		records: records,
+	}
+	if len(records) > 0 && records[len(records)-1].Seq > o.seq {
+		o.seq = records[len(records)-1].Seq
+	}
+	o.evict()
+	return o, nil
+}
+
+func (o *outbox) Put(packet *model.Packet) (uint64, error) {
+	policy := o.opts.Policies[packet.Type]
+	if policy.Discard {
+		glog.V(1).Infof("outbox discard response key %s, type %s", packet.Key, packet.Type)
+		return 0, nil
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.seq++
+	record := &Record{
+		Seq:     o.seq,
+		Created: time.Now(),
+		Packet:  packet,
+	}
+	if err := o.journal.write(record); err != nil {
+		return 0, fmt.Errorf("write outbox record %d: %v", record.Seq, err)
+	}
+	o.records = append(o.records, record)
+	o.evict()
+	return record.Seq, nil
+}
+
+func (o *outbox) List() ([]*Record, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	now := time.Now()
+	retained := make([]*Record, 0, len(o.records))
+	for _, record := range o.records {
+		policy := o.opts.Policies[record.Packet.Type]
+		if policy.MaxAge > 0 && now.Sub(record.Created) > policy.MaxAge {
+			glog.V(1).Infof("outbox expire response key %s, type %s", record.Packet.Key, record.Packet.Type)
+			if err := o.journal.delete(record.Seq); err != nil {
+				return nil, fmt.Errorf("delete outbox record %d: %v", record.Seq, err)
+			}
+			continue
+		}
+		retained = append(retained, record)
+	}
+	o.records = retained
+
+	records := make([]*Record, len(retained))
+	copy(records, retained)
+	return records, nil
+}
+
+func (o *outbox) Get(seq uint64) (*Record, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	for _, record := range o.records {
+		if record.Seq == seq {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+func (o *outbox) Remove(seq uint64) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	for i, record := range o.records {
+		if record.Seq == seq {
+			o.records = append(o.records[:i], o.records[i+1:]...)
+			return o.journal.delete(seq)
+		}
+	}
+	return nil
+}
+
+func (o *outbox) Len() int {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return len(o.records)
+}
+
+// evict drops the oldest records until the size cap is respected,
+// the caller must hold the lock.
+func (o *outbox) evict() {
+	for len(o.records) > o.opts.MaxSize {
+		oldest := o.records[0]
+		glog.Warningf("outbox full, drop response key %s, type %s", oldest.Packet.Key, oldest.Packet.Type)
+		if err := o.journal.delete(oldest.Seq); err != nil {
+			glog.Errorf("delete outbox record %d: %v", oldest.Seq, err)
+		}
+		o.records = o.records[1:]
+	}
+}
+
+// ParsePolicies converts type=retention pairs into policies. The retention is
+// either a duration such as 30m or "discard".
+func ParsePolicies(values map[string]string) (map[string]Policy, error) {
+	policies := make(map[string]Policy, len(values))
+	for packetType, value := range values {
+		value = strings.TrimSpace(value)
+		if value == PolicyDiscard {
+			policies[packetType] = Policy{Discard: true}
+			continue
+		}
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse retention of %s: %v", packetType, err)
+		}
+		policies[packetType] = Policy{MaxAge: maxAge}
+	}
+	return policies, nil
+}
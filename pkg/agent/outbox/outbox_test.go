@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+func TestFileOutboxReplayInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err, "no error create temp dir")
+	defer os.RemoveAll(dir)
+
+	o, err := NewFile(dir, Options{MaxSize: 2})
+	assert.Nil(t, err, "no error open outbox")
+	for _, key := range []string{"key01", "key02", "key03"} {
+		_, err := o.Put(&model.Packet{Key: key, Type: model.HelmInstallRelease})
+		assert.Nil(t, err, "no error put packet")
+	}
+
+	// reopen as after an agent restart
+	o, err = NewFile(dir, Options{MaxSize: 2})
+	assert.Nil(t, err, "no error reopen outbox")
+	records, err := o.List()
+	assert.Nil(t, err, "no error list records")
+	assert.Equal(t, 2, len(records), "oldest record not evicted")
+	assert.Equal(t, "key02", records[0].Packet.Key, "bad replay order")
+	assert.Equal(t, "key03", records[1].Packet.Key, "bad replay order")
+
+	assert.Nil(t, o.Remove(records[0].Seq), "no error remove record")
+	seq, err := o.Put(&model.Packet{Key: "key04", Type: model.HelmInstallRelease})
+	assert.Nil(t, err, "no error put packet")
+	assert.Equal(t, records[1].Seq+1, seq, "sequence not continued")
+	assert.Equal(t, 2, o.Len(), "bad outbox size")
+}
+
+func TestOutboxRetention(t *testing.T) {
+	policies, err := ParsePolicies(map[string]string{
+		model.StatusSyncEvent: PolicyDiscard,
+		model.ResourceUpdate:  "1ns",
+	})
+	assert.Nil(t, err, "no error parse policies")
+
+	o := NewMemory(Options{Policies: policies})
+	seq, err := o.Put(&model.Packet{Key: "env:test", Type: model.StatusSyncEvent})
+	assert.Nil(t, err, "no error put packet")
+	assert.Equal(t, uint64(0), seq, "discarded packet kept")
+	o.Put(&model.Packet{Key: "env:test", Type: model.ResourceUpdate})
+	o.Put(&model.Packet{Key: "env:test", Type: model.GitOpsSyncEvent})
+
+	time.Sleep(time.Millisecond)
+	records, err := o.List()
+	assert.Nil(t, err, "no error list records")
+	assert.Equal(t, 1, len(records), "expired packet kept")
+	assert.Equal(t, model.GitOpsSyncEvent, records[0].Packet.Type, "bad record retained")
+
+	_, err = ParsePolicies(map[string]string{model.ResourceUpdate: "forever"})
+	assert.NotNil(t, err, "bad retention accepted")
+}
+
+func TestFileOutboxReopenEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err, "no error create temp dir")
+	defer os.RemoveAll(dir)
+
+	o, err := NewFile(dir, Options{})
+	assert.Nil(t, err, "no error open outbox")
+	seq, err := o.Put(&model.Packet{Key: "key01", Type: model.HelmInstallRelease})
+	assert.Nil(t, err, "no error put packet")
+	assert.Nil(t, o.Remove(seq), "no error remove record")
+
+	// every record was replayed before the restart
+	o, err = NewFile(dir, Options{})
+	assert.Nil(t, err, "no error reopen outbox")
+	assert.Equal(t, 0, o.Len(), "removed record kept")
+	next, err := o.Put(&model.Packet{Key: "key02", Type: model.HelmInstallRelease})
+	assert.Nil(t, err, "no error put packet")
+	assert.Equal(t, seq+1, next, "sequence restarted")
+}
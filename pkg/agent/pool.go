@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+const (
+	DefaultCommandWorkers   = 10
+	DefaultCommandQueueSize = 100
+)
+
+var errPoolFull = errors.New("too many commands queued, retry later")
+
+var (
+	commandQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "choerodon_agent_command_queue_depth",
+		Help: "Number of commands waiting for a worker.",
+	})
+	commandsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "choerodon_agent_commands_in_flight",
+		Help: "Number of commands being handled.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(commandQueueDepth, commandsInFlight)
+}
+
+// workerPool runs commands on a fixed number of workers. Commands of the
+// same env and release are queued together and run one after another in
+// the order they were received, while other releases keep the remaining
+// workers busy.
+type workerPool struct {
+	mtx     sync.Mutex
+	handle  func(cmd *model.Packet)
+	workers int
+	// pending holds the commands of each key not handled yet, the head of
+	// a queue is running or about to run.
+	pending map[string][]*model.Packet
+	// ready lists the keys whose head command waits for a worker.
+	ready     chan string
+	slots     chan struct{}
+	queued    int
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newWorkerPool(workers int, queueSize int, handle func(cmd *model.Packet)) *workerPool {
+	if workers <= 0 {
+		workers = DefaultCommandWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultCommandQueueSize
+	}
+	capacity := workers * queueSize
+	return &workerPool{
+		handle:  handle,
+		workers: workers,
+		pending: map[string][]*model.Packet{},
+		// a key is listed at most once and has a command queued, so
+		// sends to ready never block
+		ready: make(chan string, capacity),
+		slots: make(chan struct{}, capacity),
+	}
+}
+
+func (p *workerPool) Start(stop <-chan struct{}) {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work(strconv.Itoa(i), stop)
+	}
+}
+
+// Dispatch queues a command behind the other commands of its key. It fails
+// rather than blocks while the pool is full, so that the caller keeps
+// receiving cancel_command and stop.
+func (p *workerPool) Dispatch(cmd *model.Packet) error {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return errPoolFull
+	}
+	commandQueueDepth.Inc()
+	key := dispatchKey(cmd)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.queued++
+	p.pending[key] = append(p.pending[key], cmd)
+	if len(p.pending[key]) == 1 {
+		p.ready <- key
+	}
+	return nil
+}
+
+// Close lets the workers exit once all queued commands are handled,
+// Dispatch must not be called afterwards.
+func (p *workerPool) Close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.closed = true
+	p.closeIfDone()
+}
+
+// closeIfDone stops the workers of a closed pool with nothing left, the
+// caller must hold the lock.
+func (p *workerPool) closeIfDone() {
+	if p.closed && p.queued == 0 {
+		p.closeOnce.Do(func() {
+			close(p.ready)
+		})
+	}
+}
+
+// Wait blocks until all workers have exited.
+func (p *workerPool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *workerPool) work(name string, stop <-chan struct{}) {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-stop:
+			glog.V(1).Infof("command worker %s down", name)
+			return
+		case key, ok := <-p.ready:
+			if !ok {
+				glog.V(1).Infof("command worker %s drained", name)
+				return
+			}
+			p.mtx.Lock()
+			cmd := p.pending[key][0]
+			p.mtx.Unlock()
+
+			commandQueueDepth.Dec()
+			commandsInFlight.Inc()
+			p.handle(cmd)
+			commandsInFlight.Dec()
+			p.done(key)
+		}
+	}
+}
+
+// done removes the handled head command of key and lists the key again if
+// more commands wait behind it.
+func (p *workerPool) done(key string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if rest := p.pending[key][1:]; len(rest) > 0 {
+		p.pending[key] = rest
+		p.ready <- key
+	} else {
+		delete(p.pending, key)
+	}
+	p.queued--
+	<-p.slots
+	p.closeIfDone()
+}
+
+// dispatchKey returns what commands are serialized on, env and release if
+// the command targets a release, its whole key otherwise.
+func dispatchKey(cmd *model.Packet) string {
+	if release := cmd.Release(); release != "" {
+		return fmt.Sprintf("env:%s.release:%s", cmd.Namespace(), release)
+	}
+	return cmd.Key
+}
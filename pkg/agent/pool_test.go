@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+func TestWorkerPoolSerializesPerRelease(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	var mtx sync.Mutex
+	var order []string
+	block := make(chan struct{})
+	p := newWorkerPool(2, 10, func(cmd *model.Packet) {
+		if cmd.Payload == "slow" {
+			<-block
+		}
+		mtx.Lock()
+		order = append(order, cmd.Payload)
+		mtx.Unlock()
+	})
+	p.Start(stop)
+
+	p.Dispatch(&model.Packet{Key: "env:a.release:a", Payload: "slow"})
+	p.Dispatch(&model.Packet{Key: "env:a.release:a", Payload: "after slow"})
+	for _, payload := range []string{"b1", "b2", "b3"} {
+		p.Dispatch(&model.Packet{Key: "env:a.release:b", Payload: payload})
+	}
+
+	// the other release is not stuck behind the slow one
+	for {
+		mtx.Lock()
+		n := len(order)
+		mtx.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	p.Close()
+	p.Wait()
+	assert.Equal(t, []string{"b1", "b2", "b3", "slow", "after slow"}, order, "bad command order")
+}
+
+func TestWorkerPoolFull(t *testing.T) {
+	stop := make(chan struct{})
+	p := newWorkerPool(1, 1, func(cmd *model.Packet) {
+		<-stop
+	})
+	p.Start(stop)
+
+	assert.NoError(t, p.Dispatch(&model.Packet{Key: "env:a.release:a"}))
+	// the full pool answers at once instead of blocking the caller
+	assert.Equal(t, errPoolFull, p.Dispatch(&model.Packet{Key: "env:a.release:b"}))
+
+	close(stop)
+	done := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers did not stop")
+	}
+}
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/choerodon/choerodon-cluster-agent/controller"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
@@ -16,6 +18,7 @@ import (
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/recorder"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
 )
 
@@ -38,9 +41,22 @@ type workerManager struct {
 	controllerContext  *controller.ControllerContext
 	wg                 *sync.WaitGroup
 	stop               <-chan struct{}
-	token              string
 	platformCode       string
 	syncAll            bool
+	commands           *commandCache
+	contexts           *commandContexts
+	pool               *workerPool
+	recorder           *recorder.Recorder
+	rolloutTimeout     time.Duration
+	// pending tracks responses on their way to the response channel and
+	// the follow-ups of commands.
+	pending   sync.WaitGroup
+	draining  chan struct{}
+	drainOnce sync.Once
+	drained   chan struct{}
+	// followUpCtx is done once the agent stops or is not drained in time.
+	followUpCtx     context.Context
+	cancelFollowUps context.CancelFunc
 }
 
 func NewWorkerManager(
@@ -57,10 +73,15 @@ func NewWorkerManager(
 	controllerContext *controller.ControllerContext,
 	wg *sync.WaitGroup,
 	stop <-chan struct{},
-	token string,
 	platformCode string,
-	syncAll bool) *workerManager {
-	return &workerManager{
+	syncAll bool,
+	commandWorkers int,
+	commandQueueSize int,
+	commandTimeout time.Duration,
+	commandTimeouts map[string]time.Duration,
+	execRecorder *recorder.Recorder,
+	releaseRolloutTimeout time.Duration) *workerManager {
+	w := &workerManager{
 		chans:              chans,
 		helmClient:         helmClient,
 		kubeClient:         kubeClient,
@@ -76,10 +97,18 @@ func NewWorkerManager(
 		stop:               stop,
 		controllerContext:  controllerContext,
 		cluster:            cluster,
-		token:              token,
 		platformCode:       platformCode,
 		syncAll:            syncAll,
+		commands:           newCommandCache(commandCacheSize, commandCacheTTL),
+		contexts:           newCommandContexts(commandTimeout, commandTimeouts),
+		recorder:           execRecorder,
+		rolloutTimeout:     releaseRolloutTimeout,
+		draining:           make(chan struct{}),
+		drained:            make(chan struct{}),
 	}
+	w.followUpCtx, w.cancelFollowUps = context.WithCancel(context.Background())
+	w.pool = newWorkerPool(commandWorkers, commandQueueSize, w.handleCommand)
+	return w
 }
 
 func (w *workerManager) Start() {
@@ -92,54 +121,185 @@ func (w *workerManager) Start() {
 
 func (w *workerManager) runWorker() {
 	defer w.wg.Done()
+	w.pool.Start(w.stop)
 	for {
 		select {
 		case <-w.stop:
 			glog.Infof("worker down!")
+			w.cancelFollowUps()
+			w.pool.Wait()
+			return
+		case <-w.draining:
+			glog.Infof("worker draining, stop accepting commands")
+			w.pool.Close()
+			w.pool.Wait()
+			w.pending.Wait()
+			close(w.drained)
 			return
 		case cmd := <-w.chans.CommandChan:
-			go func(cmd *model.Packet) {
-				glog.Infof("get command: %s/%s", cmd.Key, cmd.Type)
-				var newCmds []*model.Packet = nil
-				var resp *model.Packet = nil
-
-				if processCmdFunc, ok := command.Funcs[cmd.Type]; ok {
-					opts := &commandutil.Opts{
-						GitTimeout:        w.gitTimeout,
-						Namespaces:        w.controllerContext.Namespaces,
-						GitRepos:          w.gitRepos,
-						KubeClient:        w.kubeClient,
-						ControllerContext: w.controllerContext,
-						StopCh:            w.stop,
-						Cluster:           w.cluster,
-						Wg:                w.wg,
-						CrChan:            w.chans,
-						GitConfig:         w.gitConfig,
-						Envs:              w.agentInitOps.Envs,
-						HelmClient:        w.helmClient,
-						PlatformCode:      w.platformCode,
-						WsClient:          w.appClient,
-						Token:             w.token,
-					}
-					newCmds, resp = processCmdFunc(opts, cmd)
-				} else {
-					err := fmt.Errorf("type %s not exist", cmd.Type)
-					glog.V(1).Info(err.Error())
-				}
-
-				if newCmds != nil {
-					go func(newCmds []*model.Packet) {
-						for i := 0; i < len(newCmds); i++ {
-							w.chans.CommandChan <- newCmds[i]
-						}
-					}(newCmds)
+			if cmd.Type == model.CancelCommand {
+				// not queued, or it would wait for the command it cancels
+				w.cancelCommand(cmd)
+				continue
+			}
+			if cmd.Seq > 0 {
+				if started, done, resp := w.commands.Start(cmd); !started {
+					w.replayCommand(cmd, done, resp)
+					continue
 				}
-				if resp != nil {
-					go func(resp *model.Packet) {
-						w.chans.ResponseChan <- resp
-					}(resp)
+			}
+			w.contexts.Add(cmd)
+			if err := w.pool.Dispatch(cmd); err != nil {
+				w.contexts.Remove(cmd)
+				resp := commandutil.NewResponseError(cmd.Key, failedType(cmd.Type), err)
+				if cmd.Seq > 0 {
+					// failures are not cached, a resent command runs
+					w.commands.Finish(cmd, resp)
 				}
-			}(cmd)
+				w.respond(resp)
+			}
+		}
+	}
+}
+
+func (w *workerManager) handleCommand(cmd *model.Packet) {
+	glog.Infof("get command: %s/%s", cmd.Key, cmd.Type)
+	var newCmds []*model.Packet = nil
+	var resp *model.Packet = nil
+	var followUps []commandutil.FollowUpFunc
+
+	ctx, cancel := w.contexts.Start(cmd)
+	defer cancel()
+
+	if ctx.Err() != nil {
+		glog.Infof("skip command cancelled before it started: %s/%s", cmd.Key, cmd.Type)
+		resp = commandutil.NewResponseError(cmd.Key, failedType(cmd.Type), context.Canceled)
+	} else if processCmdFunc, ok := command.Funcs[cmd.Type]; ok {
+		opts := &commandutil.Opts{
+			GitTimeout:        w.gitTimeout,
+			Namespaces:        w.controllerContext.Namespaces,
+			GitRepos:          w.gitRepos,
+			KubeClient:        w.kubeClient,
+			ControllerContext: w.controllerContext,
+			StopCh:            w.stop,
+			Cluster:           w.cluster,
+			Wg:                w.wg,
+			CrChan:            w.chans,
+			GitConfig:         w.gitConfig,
+			Envs:              w.agentInitOps.Envs,
+			HelmClient:        w.helmClient,
+			PlatformCode:      w.platformCode,
+			WsClient:          w.appClient,
+			Token:             string(w.appClient.Token()),
+			Recorder:          w.recorder,
+
+			ReleaseRolloutTimeout: w.rolloutTimeout,
+			FollowUp: func(fn commandutil.FollowUpFunc) {
+				followUps = append(followUps, fn)
+			},
+		}
+		newCmds, resp = processCmdFunc(ctx, opts, cmd)
+	} else {
+		err := fmt.Errorf("type %s not exist", cmd.Type)
+		glog.V(1).Info(err.Error())
+	}
+
+	if cmd.Seq > 0 {
+		w.commands.Finish(cmd, resp)
+	}
+	if newCmds != nil {
+		go func(newCmds []*model.Packet) {
+			for i := 0; i < len(newCmds); i++ {
+				w.chans.CommandChan <- newCmds[i]
+			}
+		}(newCmds)
+	}
+	if resp != nil || len(followUps) > 0 {
+		w.respond(resp, followUps...)
+	}
+}
+
+// failedTypes holds the commands whose failures are not reported as
+// <type>_failed.
+var failedTypes = map[string]string{
+	model.CreateEnv:             model.EnvCreateFailed,
+	model.EnvDelete:             model.EnvDeleteFailed,
+	model.ReSyncAgent:           model.InitAgentFailed,
+	model.HelmInstallRelease:    model.HelmReleaseInstallFailed,
+	model.HelmReleasePreInstall: model.HelmReleaseInstallFailed,
+	model.HelmReleaseUpgrade:    model.HelmReleaseInstallFailed,
+	model.HelmReleasePreUpgrade: model.HelmReleaseInstallFailed,
+}
+
+// failedType returns the type a command fails with.
+func failedType(cmdType string) string {
+	if t, ok := failedTypes[cmdType]; ok {
+		return t
+	}
+	return cmdType + "_failed"
+}
+
+// replayCommand answers a command that was already received, with the
+// response of its first run if it has finished.
+func (w *workerManager) replayCommand(cmd *model.Packet, done bool, resp *model.Packet) {
+	if !done {
+		glog.Infof("drop duplicate command: %s/%s seq %d, still running", cmd.Key, cmd.Type, cmd.Seq)
+		return
+	}
+	glog.Infof("replay result of command: %s/%s seq %d", cmd.Key, cmd.Type, cmd.Seq)
+	if resp != nil {
+		w.respond(resp)
+	}
+}
+
+// respond hands resp over to the response channel, then runs the
+// follow-ups of its command.
+func (w *workerManager) respond(resp *model.Packet, followUps ...commandutil.FollowUpFunc) {
+	w.pending.Add(1)
+	go func() {
+		defer w.pending.Done()
+		if resp != nil {
+			w.chans.ResponseChan <- resp
+		}
+		for _, fn := range followUps {
+			fn(w.followUpCtx, func(resp *model.Packet) {
+				w.respond(resp)
+			})
 		}
+	}()
+}
+
+// Drain stops accepting commands and waits for the queued and running ones
+// to finish and hand over their responses. The commands still running when
+// ctx is done are cancelled.
+func (w *workerManager) Drain(ctx context.Context) error {
+	w.drainOnce.Do(func() {
+		close(w.draining)
+	})
+	select {
+	case <-w.drained:
+		glog.Infof("worker drained")
+		return nil
+	case <-ctx.Done():
+		n := w.contexts.CancelAll()
+		w.cancelFollowUps()
+		glog.Warningf("worker not drained in time, %d commands cancelled", n)
+		return ctx.Err()
+	}
+}
+
+// cancelCommand aborts the queued or running commands a cancel_command
+// points at. The aborted commands answer with their usual failure.
+func (w *workerManager) cancelCommand(cmd *model.Packet) {
+	var req model.CancelCommandRequest
+	if err := json.Unmarshal([]byte(cmd.Payload), &req); err != nil {
+		glog.Errorf("unmarshal cancel command failed %v", err)
+		return
+	}
+	if req.Seq == 0 && req.Key == "" {
+		glog.Errorf("cancel command without seq or key: %s", cmd.Payload)
+		return
 	}
+	n := w.contexts.Cancel(&req)
+	glog.Infof("cancel command seq %d key %s: %d cancelled", req.Seq, req.Key, n)
 }
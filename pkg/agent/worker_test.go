@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+func TestWorkerManagerDrain(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	w := &workerManager{
+		chans:    channel.NewCRChannel(10, 10),
+		wg:       &sync.WaitGroup{},
+		stop:     stop,
+		commands: newCommandCache(commandCacheSize, commandCacheTTL),
+		contexts: newCommandContexts(0, nil),
+		draining: make(chan struct{}),
+		drained:  make(chan struct{}),
+	}
+	w.pool = newWorkerPool(2, 10, func(cmd *model.Packet) {
+		time.Sleep(10 * time.Millisecond)
+		w.respond(&model.Packet{Key: cmd.Key, Type: cmd.Type})
+	})
+	w.wg.Add(1)
+	go w.runWorker()
+
+	for _, key := range []string{"env:a.release:a", "env:a.release:b", "env:b.release:a"} {
+		w.chans.CommandChan <- &model.Packet{Key: key, Type: model.HelmInstallRelease}
+	}
+	for len(w.chans.CommandChan) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, w.Drain(ctx), "no error drain")
+	assert.Equal(t, 3, len(w.chans.ResponseChan), "responses not handed over before drained")
+
+	w.chans.CommandChan <- &model.Packet{Key: "env:c.release:c", Type: model.HelmInstallRelease}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, len(w.chans.CommandChan), "command accepted while drained")
+}
+
+func TestWorkerManagerCancelledBeforeStart(t *testing.T) {
+	w := &workerManager{
+		chans:    channel.NewCRChannel(10, 10),
+		commands: newCommandCache(commandCacheSize, commandCacheTTL),
+		contexts: newCommandContexts(0, nil),
+	}
+	cmd := &model.Packet{Key: "env:a.release:a", Type: model.HelmInstallRelease}
+	w.contexts.Add(cmd)
+	w.contexts.Cancel(&model.CancelCommandRequest{Key: cmd.Key})
+	w.handleCommand(cmd)
+	w.pending.Wait()
+
+	resp := <-w.chans.ResponseChan
+	assert.Equal(t, model.HelmReleaseInstallFailed, resp.Type, "bad failed type")
+	assert.Equal(t, context.Canceled.Error(), resp.Payload, "bad payload")
+}
+
+func TestWorkerManagerFollowUp(t *testing.T) {
+	w := &workerManager{
+		chans: channel.NewCRChannel(10, 10),
+	}
+	w.followUpCtx, w.cancelFollowUps = context.WithCancel(context.Background())
+	defer w.cancelFollowUps()
+
+	w.respond(&model.Packet{Key: "env:a.release:a", Type: model.HelmInstallRelease},
+		func(ctx context.Context, respond func(*model.Packet)) {
+			time.Sleep(10 * time.Millisecond)
+			respond(&model.Packet{Key: "env:a.release:a", Type: model.HelmReleaseReady})
+		})
+	w.pending.Wait()
+
+	assert.Equal(t, 2, len(w.chans.ResponseChan), "follow-up not waited for")
+	assert.Equal(t, model.HelmInstallRelease, (<-w.chans.ResponseChan).Type, "follow-up answered before the command")
+	assert.Equal(t, model.HelmReleaseReady, (<-w.chans.ResponseChan).Type, "bad follow-up response")
+}
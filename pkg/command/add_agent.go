@@ -10,6 +10,7 @@ func init() {
 	Funcs.Add(model.UpgradeCluster, agent.UpgradeAgent)
 
 	Funcs.Add(model.ReSyncAgent, agent.ReSyncAgent)
+	Funcs.Add(model.RotateToken, agent.RotateToken)
 
 	Funcs.Add(model.CreateEnv, agent.AddEnv)
 	Funcs.Add(model.EnvDelete, agent.DeleteEnv)
@@ -8,9 +8,23 @@ import (
 func init() {
 	Funcs.Add(model.KubernetesGetLogs, kubernetes.LogsByKubernetes)
 	Funcs.Add(model.KubernetesExec, kubernetes.ExecByKubernetes)
+	Funcs.Add(model.KubernetesPortForward, kubernetes.PortForward)
+	Funcs.Add(model.KubernetesCopyFrom, kubernetes.CopyFrom)
+	Funcs.Add(model.KubernetesCopyTo, kubernetes.CopyTo)
+	Funcs.Add(model.KubernetesApplyResource, kubernetes.ApplyResource)
+	Funcs.Add(model.KubernetesDeleteResource, kubernetes.DeleteResource)
+	Funcs.Add(model.KubernetesGetResource, kubernetes.GetResource)
+	Funcs.Add(model.PipeClose, kubernetes.ClosePipe)
+	Funcs.Add(model.ExecRecordingList, kubernetes.ListExecRecordings)
+	Funcs.Add(model.ExecRecordingGet, kubernetes.GetExecRecording)
 	Funcs.Add(model.OperatePodCount, kubernetes.ScalePod)
+	Funcs.Add(model.RolloutRestart, kubernetes.RolloutRestart)
+	Funcs.Add(model.RolloutPause, kubernetes.RolloutPause)
+	Funcs.Add(model.RolloutResume, kubernetes.RolloutResume)
 
 	Funcs.Add(model.OperateDockerRegistrySecret, kubernetes.CreateDockerRegistrySecret)
+	Funcs.Add(model.DeleteDockerRegistrySecret, kubernetes.DeleteDockerRegistrySecret)
+	Funcs.Add(model.ListDockerRegistrySecrets, kubernetes.ListDockerRegistrySecrets)
 
 	Funcs.Add(model.NetworkService, kubernetes.CreateService)
 	Funcs.Add(model.NetworkServiceDelete, kubernetes.DeleteService)
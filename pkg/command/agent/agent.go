@@ -1,18 +1,22 @@
 package agent
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/gitops"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	commandutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
 	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
 )
 
-func InitAgent(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func InitAgent(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 
 	var agentInitOpts model.AgentInitOptions
 	err := json.Unmarshal([]byte(cmd.Payload), &agentInitOpts)
@@ -54,8 +58,8 @@ func InitAgent(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 
 }
 
-func UpgradeAgent(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	upgradeInfo, certInfo, err := opts.HelmClient.ListAgent(cmd.Payload)
+func UpgradeAgent(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	upgradeInfo, certInfo, err := opts.HelmClient.ListAgent(ctx, cmd.Payload)
 	if err != nil {
 		return nil, commandutil.NewResponseError(cmd.Key, model.UpgradeClusterFailed, err)
 	}
@@ -99,12 +103,23 @@ func UpgradeAgent(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *
 	return nil, resp
 }
 
-func ReSyncAgent(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func ReSyncAgent(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	fmt.Println("get command re_sync")
 	opts.ControllerContext.ReSync()
 	return nil, nil
 }
 
+// RotateToken switches to the token pushed by DevOps service, the agent
+// reconnects with it while pipes stay open.
+func RotateToken(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	token := strings.TrimSpace(cmd.Payload)
+	if token == "" {
+		return nil, commandutil.NewResponseError(cmd.Key, model.RotateTokenFailed, errors.New("empty token"))
+	}
+	opts.WsClient.UpdateToken(websocket.Token(token))
+	return nil, nil
+}
+
 func createNamespace(kubeClient kube.Client, namespace string) (*v1.Namespace, error) {
 	return kubeClient.GetKubeClient().CoreV1().Namespaces().Create(&v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/gitops"
@@ -10,7 +11,7 @@ import (
 )
 
 // todo reuse this code
-func AddEnv(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func AddEnv(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var agentInitOpts model.AgentInitOptions
 	err := json.Unmarshal([]byte(cmd.Payload), &agentInitOpts)
 
@@ -51,7 +52,7 @@ func AddEnv(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.
 	}
 }
 
-func DeleteEnv(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func DeleteEnv(ctx context.Context, opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var env model.EnvParas
 	err := json.Unmarshal([]byte(cmd.Payload), &env)
 
@@ -78,7 +79,7 @@ func DeleteEnv(opts *commandutil.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 	}
 	opts.Envs = newEnvs
 
-	if err := opts.HelmClient.DeleteNamespaceReleases(env.Namespace); err != nil {
+	if err := opts.HelmClient.DeleteNamespaceReleases(ctx, env.Namespace); err != nil {
 		glog.V(1).Info(err)
 	}
 	if err := opts.KubeClient.DeleteNamespace(env.Namespace); err != nil {
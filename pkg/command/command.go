@@ -1,11 +1,12 @@
 package command
 
 import (
+	"context"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 )
 
-type Func func(w *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet)
+type Func func(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet)
 
 var Funcs = FuncMap{}
 
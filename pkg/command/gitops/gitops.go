@@ -6,8 +6,8 @@ import (
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 )
 
-func DoSync(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	ctx, cancel := context.WithTimeout(context.Background(), opts.GitTimeout)
+func DoSync(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	ctx, cancel := context.WithTimeout(ctx, opts.GitTimeout)
 	if !opts.Namespaces.Contain(cmd.Namespace()) {
 		return nil, &model.Packet{
 			Key:     cmd.Key,
@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
@@ -10,7 +11,7 @@ import (
 	"time"
 )
 
-func InstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func InstallHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.InstallReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
@@ -19,7 +20,7 @@ func InstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	if req.Namespace == "" {
 		req.Namespace = cmd.Namespace()
 	}
-	resp, err := opts.HelmClient.InstallRelease(&req)
+	resp, err := opts.HelmClient.InstallRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
 	}
@@ -27,6 +28,7 @@ func InstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	if err != nil {
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
 	}
+	trackRollout(opts, cmd.Key, resp, req.Commit)
 	return nil, &model.Packet{
 		Key:     cmd.Key,
 		Type:    model.HelmInstallRelease,
@@ -34,7 +36,7 @@ func InstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	}
 }
 
-func UpgradeHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func UpgradeHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.UpgradeReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
@@ -45,7 +47,7 @@ func UpgradeHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	}
 
 	ch := opts.CrChan
-	resp, err := opts.HelmClient.UpgradeRelease(&req)
+	resp, err := opts.HelmClient.UpgradeRelease(ctx, &req)
 	if err != nil {
 		if req.ChartName == "choerodon-cluster-agent" && req.Namespace == "choerodon" {
 			go func() {
@@ -54,7 +56,10 @@ func UpgradeHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 				randWait := rand.Intn(20)
 				time.Sleep(time.Duration(randWait) * time.Second)
 				glog.Infof("start retry upgrade agent ...")
-				ch.CommandChan <- cmd
+				// a new command, or the cached failure would be replayed
+				retry := *cmd
+				retry.Seq = 0
+				ch.CommandChan <- &retry
 			}()
 		}
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
@@ -63,6 +68,7 @@ func UpgradeHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	if err != nil {
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
 	}
+	trackRollout(opts, cmd.Key, resp, req.Commit)
 	return nil, &model.Packet{
 		Key:     cmd.Key,
 		Type:    model.HelmReleaseUpgrade,
@@ -70,13 +76,13 @@ func UpgradeHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	}
 }
 
-func RollbackHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func RollbackHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.RollbackReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseRollbackFailed, err)
 	}
-	resp, err := opts.HelmClient.RollbackRelease(&req)
+	resp, err := opts.HelmClient.RollbackRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseRollbackFailed, err)
 	}
@@ -91,13 +97,13 @@ func RollbackHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet
 	}
 }
 
-func DeleteHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func DeleteHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.DeleteReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseDeleteFailed, err)
 	}
-	deleteResp, err := opts.HelmClient.DeleteRelease(&req)
+	deleteResp, err := opts.HelmClient.DeleteRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseDeleteFailed, err)
 	}
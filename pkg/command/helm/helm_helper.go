@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
@@ -10,13 +11,13 @@ import (
 )
 
 // todo: maybe a wrong operator
-func StartHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func StartHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.StartReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseStartFailed, err)
 	}
-	startResp, err := opts.HelmClient.StartRelease(&req)
+	startResp, err := opts.HelmClient.StartRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseStartFailed, err)
 	}
@@ -31,13 +32,13 @@ func StartHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *
 	}
 }
 
-func StopHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func StopHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.StopReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseStopFailed, err)
 	}
-	resp, err := opts.HelmClient.StopRelease(&req)
+	resp, err := opts.HelmClient.StopRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseStopFailed, err)
 	}
@@ -52,13 +53,13 @@ func StopHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *m
 	}
 }
 
-func GetHelmReleaseContent(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func GetHelmReleaseContent(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.GetReleaseContentRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseGetContentFailed, err)
 	}
-	resp, err := opts.HelmClient.GetReleaseContent(&req)
+	resp, err := opts.HelmClient.GetReleaseContent(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.HelmReleaseGetContentFailed, err)
 	}
@@ -76,7 +77,7 @@ func GetHelmReleaseContent(opts *command.Opts, cmd *model.Packet) ([]*model.Pack
 	}
 }
 
-func SyncStatus(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func SyncStatus(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var reqs []helm.SyncRequest
 	var reps = make([]*helm.SyncRequest, 0)
 
@@ -122,7 +123,7 @@ func SyncStatus(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.
 				reps = append(reps, newSyncResponse(syncRequest.ResourceName, syncRequest.ResourceType, "", syncRequest.Id))
 			} else if chr != nil {
 				if chr.Annotations[model.CommitLabel] == syncRequest.Commit {
-					release, err := helmClient.GetRelease(&helm.GetReleaseContentRequest{ReleaseName: syncRequest.ResourceName})
+					release, err := helmClient.GetRelease(ctx, &helm.GetReleaseContentRequest{ReleaseName: syncRequest.ResourceName})
 					if err != nil {
 						glog.Infof("release %s get error ", syncRequest.ResourceName, err)
 						if strings.Contains(err.Error(), "not exist") {
@@ -1,13 +1,14 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 )
 
-func PreInstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func PreInstallHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.InstallReleaseRequest
 	var newCmds []*model.Packet
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
@@ -18,7 +19,7 @@ func PreInstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Pack
 	if req.Namespace == "" {
 		req.Namespace = cmd.Namespace()
 	}
-	hooks, err := opts.HelmClient.PreInstallRelease(&req)
+	hooks, err := opts.HelmClient.PreInstallRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
 	}
@@ -40,7 +41,7 @@ func PreInstallHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Pack
 	return newCmds, resp
 }
 
-func PreUpdateHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func PreUpdateHelmRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.UpgradeReleaseRequest
 	var newCmds []*model.Packet
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
@@ -50,7 +51,7 @@ func PreUpdateHelmRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packe
 	if req.Namespace == "" {
 		req.Namespace = cmd.Namespace()
 	}
-	hooks, err := opts.HelmClient.PreUpgradeRelease(&req)
+	hooks, err := opts.HelmClient.PreUpgradeRelease(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseErrorWithCommit(cmd.Key, req.Commit, model.HelmReleaseInstallFailed, err)
 	}
@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
@@ -9,14 +10,14 @@ import (
 	"strings"
 )
 
-func ExecuteTestRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func ExecuteTestRelease(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req helm.TestReleaseRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.ExecuteTestFailed, err)
 	}
 	req.Label = opts.PlatformCode
-	resp, err := opts.HelmClient.ExecuteTest(&req)
+	resp, err := opts.HelmClient.ExecuteTest(ctx, &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.ExecuteTestFailed, err)
 	}
@@ -31,7 +32,7 @@ func ExecuteTestRelease(opts *command.Opts, cmd *model.Packet) ([]*model.Packet,
 	}
 }
 
-func GetTestStatus(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func GetTestStatus(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	releaseNames := make([]string, 0)
 	err := json.Unmarshal([]byte(cmd.Payload), &releaseNames)
 	if err != nil {
@@ -41,7 +42,7 @@ func GetTestStatus(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 
 	releasesStatus := make([]helm.TestReleaseStatus, 0)
 	for _, rls := range releaseNames {
-		status := releaseStatus(opts, rls)
+		status := releaseStatus(ctx, opts, rls)
 		if status != "" {
 			testRlsStatus := helm.TestReleaseStatus{
 				ReleaseName: rls,
@@ -64,8 +65,8 @@ func GetTestStatus(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 	}
 }
 
-func releaseStatus(opts *command.Opts, releaseName string) string {
-	_, err := opts.HelmClient.GetRelease(&helm.GetReleaseContentRequest{ReleaseName: releaseName})
+func releaseStatus(ctx context.Context, opts *command.Opts, releaseName string) string {
+	_, err := opts.HelmClient.GetRelease(ctx, &helm.GetReleaseContentRequest{ReleaseName: releaseName})
 	if err != nil {
 		if strings.Contains(err.Error(), "not exist") {
 			return "delete"
@@ -0,0 +1,100 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/helm"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+const rolloutPollInterval = 3 * time.Second
+
+type ReleaseRollout struct {
+	ReleaseName string                    `json:"releaseName"`
+	Namespace   string                    `json:"namespace"`
+	Revision    int32                     `json:"revision,omitempty"`
+	Commit      string                    `json:"commit,omitempty"`
+	Message     string                    `json:"message,omitempty"`
+	Resources   []*kube.ResourceReadiness `json:"resources"`
+}
+
+// trackRollout waits for the workloads of a release installed or upgraded
+// to roll out once the response of the command is sent, then tells DevOps
+// service whether the release is ready.
+func trackRollout(opts *command.Opts, key string, release *helm.Release, commit string) {
+	timeout := opts.ReleaseRolloutTimeout
+	if timeout <= 0 || release == nil || opts.FollowUp == nil {
+		return
+	}
+	rollout := &ReleaseRollout{
+		ReleaseName: release.Name,
+		Namespace:   release.Namespace,
+		Revision:    release.Revision,
+		Commit:      commit,
+	}
+	opts.FollowUp(func(ctx context.Context, respond func(*model.Packet)) {
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+		ticker := time.NewTicker(rolloutPollInterval)
+		defer ticker.Stop()
+		for {
+			readiness, err := opts.KubeClient.GetReadiness(release.Namespace, release.Manifest)
+			if err != nil {
+				glog.Warningf("get readiness of release %s: %v", release.Name, err)
+			} else {
+				rollout.Resources = readiness
+				ready, failed := summarize(readiness)
+				if ready {
+					sendRollout(respond, key, model.HelmReleaseReady, rollout)
+					return
+				}
+				if failed != nil {
+					rollout.Message = fmt.Sprintf("%s %s failed: %s", failed.Kind, failed.Name, failed.Message)
+					sendRollout(respond, key, model.HelmReleaseRolloutFailed, rollout)
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline.C:
+				rollout.Message = fmt.Sprintf("release not ready after %s", timeout)
+				sendRollout(respond, key, model.HelmReleaseRolloutFailed, rollout)
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// summarize returns whether all resources are ready, or the first failed.
+func summarize(readiness []*kube.ResourceReadiness) (bool, *kube.ResourceReadiness) {
+	ready := true
+	for _, r := range readiness {
+		if r.Failed {
+			return false, r
+		}
+		ready = ready && r.Ready
+	}
+	return ready, nil
+}
+
+func sendRollout(respond func(*model.Packet), key string, packetType string, rollout *ReleaseRollout) {
+	content, err := json.Marshal(rollout)
+	if err != nil {
+		glog.Errorf("marshal rollout of release %s: %v", rollout.ReleaseName, err)
+		return
+	}
+	respond(&model.Packet{
+		Key:     key,
+		Type:    packetType,
+		Payload: string(content),
+	})
+}
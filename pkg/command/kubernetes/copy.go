@@ -0,0 +1,120 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
+)
+
+type CopyRequest struct {
+	PodName       string `json:"podName,omitempty"`
+	ContainerName string `json:"containerName,omitempty"`
+	Path          string `json:"path,omitempty"`
+	PipeID        string `json:"pipeID,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+}
+
+type CopyResponse struct {
+	Path  string `json:"path,omitempty"`
+	Bytes int64  `json:"bytes"`
+}
+
+func (req *CopyRequest) options() *kube.CopyOptions {
+	return &kube.CopyOptions{
+		Namespace:     req.Namespace,
+		PodName:       req.PodName,
+		ContainerName: req.ContainerName,
+		Path:          req.Path,
+	}
+}
+
+// CopyFrom sends a tar archive of a container path over a copy pipe, the
+// pipe is closed normally once the whole archive is sent.
+func CopyFrom(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req, err := parseCopyRequest(cmd)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyFromFailed, err)
+	}
+	pr, pw := io.Pipe()
+	remote := struct {
+		io.Reader
+		io.Writer
+	}{pr, ioutil.Discard}
+	pipe, err := websocket.NewPipeFromEnds(nil, remote, opts.WsClient, req.PipeID, pipeutil.Copy)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyFromFailed, err)
+	}
+	copyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pipe.OnClose(func() {
+		pw.CloseWithError(io.ErrClosedPipe)
+		cancel()
+	})
+
+	n, err := opts.KubeClient.CopyFrom(copyCtx, req.options(), pw)
+	if err != nil {
+		pipe.Close()
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyFromFailed, err)
+	}
+	// the end of the archive, the pipe closes once it is read
+	pw.Close()
+	return nil, copyResponse(cmd.Key, model.KubernetesCopyFrom, req.Path, n)
+}
+
+// CopyTo extracts the tar archive DevOps service sends over a copy pipe in
+// a container directory, the archive ends when DevOps service closes the pipe.
+func CopyTo(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req, err := parseCopyRequest(cmd)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyToFailed, err)
+	}
+	pr, pw := io.Pipe()
+	// nothing is sent back, reads block until the pipe is closed
+	idleReader, idleWriter := io.Pipe()
+	remote := struct {
+		io.Reader
+		io.Writer
+	}{idleReader, pw}
+	pipe, err := websocket.NewPipeFromEnds(nil, remote, opts.WsClient, req.PipeID, pipeutil.Copy)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyToFailed, err)
+	}
+	pipe.OnClose(func() {
+		pw.Close()
+		idleWriter.Close()
+	})
+	defer pipe.Close()
+
+	n, err := opts.KubeClient.CopyTo(ctx, req.options(), pr)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesCopyToFailed, err)
+	}
+	return nil, copyResponse(cmd.Key, model.KubernetesCopyTo, req.Path, n)
+}
+
+func parseCopyRequest(cmd *model.Packet) (*CopyRequest, error) {
+	var req *CopyRequest
+	if err := json.Unmarshal([]byte(cmd.Payload), &req); err != nil {
+		return nil, err
+	}
+	if err := kube.ValidateCopyPath(req.Path); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func copyResponse(key string, packetType string, path string, n int64) *model.Packet {
+	content, _ := json.Marshal(&CopyResponse{Path: path, Bytes: n})
+	return &model.Packet{
+		Key:     key,
+		Type:    packetType,
+		Payload: string(content),
+	}
+}
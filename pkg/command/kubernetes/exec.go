@@ -1,11 +1,15 @@
 package kubernetes
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/recorder"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
+	"github.com/golang/glog"
 )
 
 type ExecByKubernetesRequest struct {
@@ -13,19 +17,81 @@ type ExecByKubernetesRequest struct {
 	ContainerName string `json:"containerName,omitempty"`
 	PipeID        string `json:"pipeID,omitempty"`
 	Namespace     string `json:"namespace,omitempty"`
+	// Command runs instead of a shell, such as ["cat", "/etc/hosts"].
+	Command []string `json:"command,omitempty"`
+	// Tty defaults to true, as for a web shell.
+	Tty    *bool  `json:"tty,omitempty"`
+	Width  uint16 `json:"width,omitempty"`
+	Height uint16 `json:"height,omitempty"`
+	// User is who opened the session, kept with its recording.
+	User string `json:"user,omitempty"`
 }
 
-func ExecByKubernetes(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func ExecByKubernetes(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req *ExecByKubernetesRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.KubernetesExecFailed, err)
 	}
-	pipe, err := websocket.NewPipe(opts.WsClient, req.PipeID, pipeutil.Exec)
+	execOpts := &kube.ExecOptions{
+		Namespace:     req.Namespace,
+		PodName:       req.PodName,
+		ContainerName: req.ContainerName,
+		Command:       req.Command,
+		TTY:           req.Tty == nil || *req.Tty,
+	}
+	var pipe pipeutil.Pipe
+	if execOpts.TTY {
+		sizes := pipeutil.NewTerminalSizeQueue(pipeutil.TerminalSize{Width: req.Width, Height: req.Height})
+		execOpts.TerminalSizeQueue = sizes
+		pipe, err = websocket.NewTerminalPipe(opts.WsClient, req.PipeID, sizes)
+	} else {
+		pipe, err = websocket.NewPipe(opts.WsClient, req.PipeID, pipeutil.Exec)
+	}
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.KubernetesExecFailed, err)
 	}
+	// the session lasts as long as the user keeps the terminal open, do not
+	// hold a command worker for it or bind it to the command deadline
+	pipeCtx, cancel := context.WithCancel(context.Background())
+	pipe.OnClose(cancel)
 	local, _ := pipe.Ends()
-	opts.KubeClient.Exec(req.Namespace, req.PodName, req.ContainerName, local)
+	session := startRecording(opts.Recorder, req)
+	if session != nil {
+		local = session.ReadWriter(local)
+		if execOpts.TerminalSizeQueue != nil {
+			execOpts.TerminalSizeQueue = session.SizeQueue(execOpts.TerminalSizeQueue)
+		}
+	}
+	go func() {
+		if err := opts.KubeClient.Exec(pipeCtx, execOpts, local); err != nil && pipeCtx.Err() == nil {
+			glog.Errorf("exec %s/%s: %v", req.Namespace, req.PodName, err)
+		}
+		pipe.Close()
+		if session != nil {
+			session.Close()
+		}
+	}()
 	return nil, nil
 }
+
+// startRecording returns nil if recording is off or fails, the session is
+// not refused because of it.
+func startRecording(r *recorder.Recorder, req *ExecByKubernetesRequest) *recorder.Session {
+	if r == nil {
+		return nil
+	}
+	session, err := r.Start(recorder.Tags{
+		PipeID:        req.PipeID,
+		Namespace:     req.Namespace,
+		PodName:       req.PodName,
+		ContainerName: req.ContainerName,
+		User:          req.User,
+		Command:       req.Command,
+	}, req.Width, req.Height)
+	if err != nil {
+		glog.Errorf("record exec session %s: %v", req.PipeID, err)
+		return nil
+	}
+	return session
+}
@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+type GetResourceRequest struct {
+	Namespace  string `json:"namespace,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// StripManagedFields and StripStatus leave out what users rarely read.
+	StripManagedFields bool `json:"stripManagedFields,omitempty"`
+	StripStatus        bool `json:"stripStatus,omitempty"`
+	// NoEvents skips looking up the events of the object.
+	NoEvents bool `json:"noEvents,omitempty"`
+}
+
+type GetResourceResponse struct {
+	Object *unstructured.Unstructured `json:"object"`
+	Yaml   string                     `json:"yaml"`
+	Events []corev1.Event             `json:"events"`
+}
+
+// GetResource answers with the live object of any kind in a managed
+// namespace, as JSON and YAML, and with its events.
+func GetResource(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req := &GetResourceRequest{}
+	err := json.Unmarshal([]byte(cmd.Payload), req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, err)
+	}
+	if req.Namespace == "" {
+		req.Namespace = cmd.Namespace()
+	}
+	if !opts.Namespaces.Contain(req.Namespace) {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, fmt.Errorf("namespace %s is not managed by the agent", req.Namespace))
+	}
+	if req.Kind == "" || req.Name == "" {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, fmt.Errorf("kind and name are required"))
+	}
+
+	obj, err := opts.KubeClient.GetResource(req.Namespace, req.APIVersion, req.Kind, req.Name)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, err)
+	}
+	resp := &GetResourceResponse{Events: []corev1.Event{}}
+	if !req.NoEvents {
+		events, err := opts.KubeClient.GetResourceEvents(obj)
+		if err != nil {
+			// the object is still worth showing
+			glog.Warningf("get events of %s %s/%s: %v", obj.GetKind(), req.Namespace, req.Name, err)
+		} else {
+			resp.Events = events
+		}
+	}
+	if req.StripManagedFields {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	}
+	if req.StripStatus {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+	resp.Object = obj
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, err)
+	}
+	resp.Yaml = string(content)
+
+	content, err = json.Marshal(resp)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetResourceFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.KubernetesGetResource,
+		Payload: string(content),
+	}
+}
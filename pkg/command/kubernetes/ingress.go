@@ -1,13 +1,14 @@
 package kubernetes
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 )
 
-func CreateIngress(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	ing, err := opts.KubeClient.CreateOrUpdateIngress(cmd.Namespace(), cmd.Payload)
+func CreateIngress(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	ing, err := opts.KubeClient.CreateOrUpdateIngress(ctx, cmd.Namespace(), cmd.Payload)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.NetworkIngressFailed, err)
 	}
@@ -23,8 +24,8 @@ func CreateIngress(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 	return nil, resp
 }
 
-func DeleteIngress(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	err := opts.KubeClient.DeleteIngress(cmd.Namespace(), cmd.Payload)
+func DeleteIngress(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	err := opts.KubeClient.DeleteIngress(ctx, cmd.Namespace(), cmd.Payload)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.NetworkIngressDeleteFailed, err)
 	}
@@ -1,45 +1,160 @@
 package kubernetes
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
 	"io"
 	"io/ioutil"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 )
 
+const defaultTailLines int64 = 1000
+
 type GetLogsByKubernetesRequest struct {
 	PodName       string `json:"podName,omitempty"`
 	ContainerName string `json:"containerName,omitempty"`
 	PipeID        string `json:"pipeID,omitempty"`
 	Namespace     string `json:"namespace,omitempty"`
+	// Previous shows the logs of the last terminated container, such as one
+	// in a crash loop.
+	Previous     bool   `json:"previous,omitempty"`
+	SinceSeconds *int64 `json:"sinceSeconds,omitempty"`
+	// SinceTime is RFC3339, only one of SinceSeconds and SinceTime may be
+	// given.
+	SinceTime  string `json:"sinceTime,omitempty"`
+	Timestamps bool   `json:"timestamps,omitempty"`
+	// TailLines defaults to 1000 if no since is given, downloads are only
+	// bound by MaxBytes.
+	TailLines  *int64 `json:"tailLines,omitempty"`
+	LimitBytes *int64 `json:"limitBytes,omitempty"`
+	// LabelSelector merges the logs of all matching pods instead of showing
+	// the ones of PodName, Release selects the pods of a release.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	Release       string `json:"release,omitempty"`
+	// Include and Exclude are regexps the lines must and must not match.
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+	// Download sends the last MaxBytes of the logs gzip'd and closes the
+	// pipe instead of following them, MaxBytes defaults to 10MiB.
+	Download bool  `json:"download,omitempty"`
+	MaxBytes int64 `json:"maxBytes,omitempty"`
 }
 
-func LogsByKubernetes(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+func LogsByKubernetes(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req *GetLogsByKubernetesRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
 	}
-	readCloser, err := opts.KubeClient.GetLogs(req.Namespace, req.PodName, req.ContainerName)
+	logOptions, err := req.logOptions()
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
 	}
+	// the stream follows the logs until the pipe is closed, a download is
+	// bound to the command deadline
+	streamCtx := context.Background()
+	if req.Download {
+		streamCtx = ctx
+	}
+	pipeCtx, cancel := context.WithCancel(streamCtx)
+	var readCloser io.ReadCloser
+	if selector := req.selector(); selector != "" {
+		readCloser, err = opts.KubeClient.GetSelectorLogs(pipeCtx, req.Namespace, selector, logOptions)
+	} else {
+		readCloser, err = opts.KubeClient.GetLogs(pipeCtx, req.Namespace, req.PodName, logOptions)
+	}
+	if err != nil {
+		cancel()
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
+	}
+	logs, err := filterLogs(readCloser, req.Include, req.Exclude)
+	if err != nil {
+		cancel()
+		readCloser.Close()
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
+	}
+	if req.Download {
+		content, err := downloadLogs(logs, req.downloadBytes())
+		cancel()
+		readCloser.Close()
+		if err != nil {
+			return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
+		}
+		logs = bytes.NewReader(content)
+	}
 	readWriter := struct {
 		io.Reader
 		io.Writer
 	}{
-		readCloser,
+		logs,
 		ioutil.Discard,
 	}
 	pipe, err := websocket.NewPipeFromEnds(nil, readWriter, opts.WsClient, req.PipeID, pipeutil.Log)
 	if err != nil {
+		cancel()
+		readCloser.Close()
 		return nil, command.NewResponseError(cmd.Key, model.KubernetesGetLogsFailed, err)
 	}
 	pipe.OnClose(func() {
+		cancel()
 		readCloser.Close()
 	})
 	return nil, nil
 }
+
+func (req *GetLogsByKubernetesRequest) selector() string {
+	if req.LabelSelector != "" {
+		return req.LabelSelector
+	}
+	if req.Release != "" {
+		return fmt.Sprintf("%s=%s", model.ReleaseLabel, req.Release)
+	}
+	return ""
+}
+
+func (req *GetLogsByKubernetesRequest) downloadBytes() int64 {
+	if req.MaxBytes <= 0 {
+		return defaultDownloadBytes
+	}
+	if req.MaxBytes > maxDownloadBytes {
+		return maxDownloadBytes
+	}
+	return req.MaxBytes
+}
+
+func (req *GetLogsByKubernetesRequest) logOptions() (*core_v1.PodLogOptions, error) {
+	options := &core_v1.PodLogOptions{
+		Container: req.ContainerName,
+		// the logs of a terminated container do not grow
+		Follow:       !req.Previous && !req.Download,
+		Previous:     req.Previous,
+		SinceSeconds: req.SinceSeconds,
+		Timestamps:   req.Timestamps,
+		TailLines:    req.TailLines,
+		LimitBytes:   req.LimitBytes,
+	}
+	if req.SinceTime != "" {
+		if req.SinceSeconds != nil {
+			return nil, fmt.Errorf("only one of sinceSeconds and sinceTime may be given")
+		}
+		sinceTime, err := time.Parse(time.RFC3339, req.SinceTime)
+		if err != nil {
+			return nil, fmt.Errorf("parse sinceTime: %v", err)
+		}
+		t := meta_v1.NewTime(sinceTime)
+		options.SinceTime = &t
+	}
+	if !req.Download && options.TailLines == nil && options.SinceSeconds == nil && options.SinceTime == nil {
+		tailLines := defaultTailLines
+		options.TailLines = &tailLines
+	}
+	return options, nil
+}
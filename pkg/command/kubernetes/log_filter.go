@@ -0,0 +1,109 @@
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+const (
+	defaultDownloadBytes int64 = 10 << 20
+	maxDownloadBytes     int64 = 100 << 20
+)
+
+// filteredLogs passes on the lines matching include and not matching
+// exclude, either may be nil.
+type filteredLogs struct {
+	reader  *bufio.Reader
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	pending []byte
+	err     error
+}
+
+func filterLogs(r io.Reader, include, exclude string) (io.Reader, error) {
+	if include == "" && exclude == "" {
+		return r, nil
+	}
+	f := &filteredLogs{reader: bufio.NewReader(r)}
+	var err error
+	if include != "" {
+		if f.include, err = regexp.Compile(include); err != nil {
+			return nil, fmt.Errorf("parse include: %v", err)
+		}
+	}
+	if exclude != "" {
+		if f.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("parse exclude: %v", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *filteredLogs) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		line, err := f.reader.ReadBytes('\n')
+		f.err = err
+		if len(line) > 0 && f.match(line) {
+			f.pending = line
+		}
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+func (f *filteredLogs) match(line []byte) bool {
+	if f.include != nil && !f.include.Match(line) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.Match(line)
+}
+
+// downloadLogs reads the logs to their end and returns the last maxBytes of
+// them, cut at a line start, gzip'd.
+func downloadLogs(r io.Reader, maxBytes int64) ([]byte, error) {
+	var tail []byte
+	truncated := false
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		tail = append(tail, buf[:n]...)
+		// trim now and then rather than on every read
+		if int64(len(tail)) > 2*maxBytes {
+			tail = append(tail[:0:0], tail[int64(len(tail))-maxBytes:]...)
+			truncated = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if int64(len(tail)) > maxBytes {
+		tail = tail[int64(len(tail))-maxBytes:]
+		truncated = true
+	}
+	if truncated {
+		if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+			tail = tail[i+1:]
+		}
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write(tail); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return gz.Bytes(), nil
+}
@@ -0,0 +1,35 @@
+package kubernetes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterLogs(t *testing.T) {
+	logs := "INFO start\nERROR disk full\nDEBUG tick\nERROR retry failed"
+	r, err := filterLogs(strings.NewReader(logs), "ERROR|WARN", "retry")
+	assert.Nil(t, err, "no error create filter")
+	content, err := ioutil.ReadAll(r)
+	assert.Nil(t, err, "no error read filtered logs")
+	assert.Equal(t, "ERROR disk full\n", string(content), "bad filtered logs")
+
+	_, err = filterLogs(strings.NewReader(logs), "(", "")
+	assert.NotNil(t, err, "bad include accepted")
+}
+
+func TestDownloadLogs(t *testing.T) {
+	logs := strings.Repeat("0123456789\n", 100)
+	content, err := downloadLogs(strings.NewReader(logs), 25)
+	assert.Nil(t, err, "no error download logs")
+
+	r, err := gzip.NewReader(bytes.NewReader(content))
+	assert.Nil(t, err, "download not gzip'd")
+	tail, err := ioutil.ReadAll(r)
+	assert.Nil(t, err, "no error gunzip download")
+	assert.Equal(t, "0123456789\n0123456789\n", string(tail), "tail not cut at a line start")
+}
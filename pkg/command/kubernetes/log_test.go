@@ -0,0 +1,30 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogOptionsSince(t *testing.T) {
+	sinceSeconds := int64(60)
+	req := &GetLogsByKubernetesRequest{SinceSeconds: &sinceSeconds, SinceTime: "2019-01-01T00:00:00Z"}
+	_, err := req.logOptions()
+	assert.NotNil(t, err, "error both since given")
+
+	req = &GetLogsByKubernetesRequest{SinceTime: "2019-01-01T00:00:00Z"}
+	options, err := req.logOptions()
+	assert.Nil(t, err, "no error since time")
+	assert.Nil(t, options.SinceSeconds, "since seconds set")
+	assert.NotNil(t, options.SinceTime, "since time not set")
+}
+
+func TestLogOptionsDefaultTail(t *testing.T) {
+	options, err := (&GetLogsByKubernetesRequest{}).logOptions()
+	assert.Nil(t, err, "no error default options")
+	assert.Equal(t, defaultTailLines, *options.TailLines, "bad default tail")
+
+	options, err = (&GetLogsByKubernetesRequest{Download: true}).logOptions()
+	assert.Nil(t, err, "no error download options")
+	assert.Nil(t, options.TailLines, "download tailed")
+}
@@ -0,0 +1,30 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/golang/glog"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+type ClosePipeRequest struct {
+	PipeID string `json:"pipeID,omitempty"`
+}
+
+// ClosePipe tears down an exec or log pipe DevOps service no longer needs,
+// along with the kube stream behind it.
+func ClosePipe(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	var req *ClosePipeRequest
+	err := json.Unmarshal([]byte(cmd.Payload), &req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.PipeCloseFailed, err)
+	}
+	if !opts.WsClient.ClosePipe(req.PipeID) {
+		// closed from this side already, such as after an idle timeout
+		glog.V(1).Infof("pipe %s to close is not open", req.PipeID)
+	}
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
+)
+
+type PortForwardRequest struct {
+	PodName   string `json:"podName,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	PipeID    string `json:"pipeID,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PortForward tunnels the TCP stream of a portforward pipe to a pod port,
+// such as a database of a dev env.
+func PortForward(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	var req *PortForwardRequest
+	err := json.Unmarshal([]byte(cmd.Payload), &req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesPortForwardFailed, err)
+	}
+	if req.Port <= 0 || req.Port > 65535 {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesPortForwardFailed, fmt.Errorf("invalid port %d", req.Port))
+	}
+	pipe, err := websocket.NewPipe(opts.WsClient, req.PipeID, pipeutil.PortForward)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesPortForwardFailed, err)
+	}
+	// the tunnel lasts until either side closes it
+	pipeCtx, cancel := context.WithCancel(context.Background())
+	pipe.OnClose(cancel)
+	local, _ := pipe.Ends()
+	go func() {
+		if err := opts.KubeClient.PortForward(pipeCtx, req.Namespace, req.PodName, req.Port, local); err != nil && pipeCtx.Err() == nil {
+			glog.Errorf("port forward %s/%s:%d: %v", req.Namespace, req.PodName, req.Port, err)
+		}
+		pipe.Close()
+	}()
+	return nil, nil
+}
@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+var errRecordingOff = errors.New("exec session recording is not enabled")
+
+type GetExecRecordingRequest struct {
+	PipeID string `json:"pipeID,omitempty"`
+}
+
+// ListExecRecordings answers with the tags of the recorded exec sessions.
+func ListExecRecordings(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	if opts.Recorder == nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingListFailed, errRecordingOff)
+	}
+	recordings, err := opts.Recorder.List()
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingListFailed, err)
+	}
+	content, err := json.Marshal(recordings)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingListFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.ExecRecordingList,
+		Payload: string(content),
+	}
+}
+
+// GetExecRecording answers with the asciinema cast file of an exec session.
+func GetExecRecording(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	if opts.Recorder == nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingGetFailed, errRecordingOff)
+	}
+	var req *GetExecRecordingRequest
+	err := json.Unmarshal([]byte(cmd.Payload), &req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingGetFailed, err)
+	}
+	content, err := opts.Recorder.Get(req.PipeID)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ExecRecordingGetFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.ExecRecordingGet,
+		Payload: string(content),
+	}
+}
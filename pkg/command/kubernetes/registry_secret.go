@@ -1,37 +1,77 @@
 package kubernetes
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 )
 
-func CreateDockerRegistrySecret(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	dockerCfg := &DockerConfigEntry{}
-	err := json.Unmarshal([]byte(cmd.Payload), dockerCfg)
+// DockerRegistrySecretRequest holds the auths of one or more registries,
+// the single registry of old requests is in the embedded entry.
+type DockerRegistrySecretRequest struct {
+	DockerConfigEntry
+	Registries []DockerConfigEntry `json:"registries,omitempty"`
+}
+
+// DockerRegistrySecret describes a registry secret without its passwords.
+type DockerRegistrySecret struct {
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace"`
+	Servers   []DockerRegistryServer `json:"servers"`
+}
+
+type DockerRegistryServer struct {
+	Server   string `json:"server"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+func CreateDockerRegistrySecret(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req := &DockerRegistrySecretRequest{}
+	err := json.Unmarshal([]byte(cmd.Payload), req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, err)
 	}
+	if req.Name == "" {
+		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, fmt.Errorf("secret name is required"))
+	}
+	if !opts.Namespaces.Contain(req.Namespace) {
+		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, fmt.Errorf("namespace %s is not managed by the agent", req.Namespace))
+	}
+	registries := req.Registries
+	if len(registries) == 0 {
+		registries = []DockerConfigEntry{req.DockerConfigEntry}
+	}
 	raw := &corev1.Secret{}
-	raw.SetName(dockerCfg.Name)
-	dockerCfgJSONContent, err := handleDockerCfgJSONContent(
-		dockerCfg.Username,
-		dockerCfg.Password,
-		dockerCfg.Email,
-		dockerCfg.Server,
-	)
+	raw.SetName(req.Name)
+	dockerCfgJSONContent, err := handleDockerCfgJSONContent(registries)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, err)
 	}
 	raw.Data = make(map[string][]byte, 0)
 	raw.Data[corev1.DockerConfigJsonKey] = dockerCfgJSONContent
 	raw.Type = corev1.SecretTypeDockerConfigJson
-	secret, err := opts.KubeClient.CreateOrUpdateDockerRegistrySecret(dockerCfg.Namespace, raw)
+	secret, err := opts.KubeClient.CreateOrUpdateDockerRegistrySecret(ctx, req.Namespace, raw)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, err)
 	}
+	// pods of the env pull with the secret without listing it themselves
+	err = opts.KubeClient.SetImagePullSecret(req.Namespace, kube.DefaultServiceAccount, req.Name, true)
+	if errors.IsNotFound(err) {
+		glog.Warningf("no service account %s/%s to pull with secret %s", req.Namespace, kube.DefaultServiceAccount, req.Name)
+	} else if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, err)
+	}
 	secretStr, err := json.Marshal(secret)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperateDockerRegistrySecretFailed, err)
@@ -44,17 +84,115 @@ func CreateDockerRegistrySecret(opts *command.Opts, cmd *model.Packet) ([]*model
 	return nil, resp
 }
 
-// handleDockerCfgJSONContent serializes a ~/.docker/config.json file
-func handleDockerCfgJSONContent(username, password, email, server string) ([]byte, error) {
-	dockercfgAuth := DockerConfigEntry{
-		Username: username,
-		Password: password,
-		Email:    email,
-		Auth:     encodeDockerConfigFieldAuth(username, password),
+// DeleteDockerRegistrySecret deletes a registry secret and removes it from
+// the default service account.
+func DeleteDockerRegistrySecret(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req := &DockerConfigEntry{}
+	err := json.Unmarshal([]byte(cmd.Payload), req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.DeleteDockerRegistrySecretFailed, err)
+	}
+	if req.Name == "" {
+		return nil, command.NewResponseError(cmd.Key, model.DeleteDockerRegistrySecretFailed, fmt.Errorf("secret name is required"))
+	}
+	if !opts.Namespaces.Contain(req.Namespace) {
+		return nil, command.NewResponseError(cmd.Key, model.DeleteDockerRegistrySecretFailed, fmt.Errorf("namespace %s is not managed by the agent", req.Namespace))
+	}
+	err = opts.KubeClient.DeleteDockerRegistrySecret(ctx, req.Namespace, req.Name)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.DeleteDockerRegistrySecretFailed, err)
+	}
+	err = opts.KubeClient.SetImagePullSecret(req.Namespace, kube.DefaultServiceAccount, req.Name, false)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, command.NewResponseError(cmd.Key, model.DeleteDockerRegistrySecretFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.DeleteDockerRegistrySecret,
+		Payload: cmd.Payload,
+	}
+}
+
+// ListDockerRegistrySecrets answers with the registry secrets of a namespace
+// and their servers, never with their passwords.
+func ListDockerRegistrySecrets(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req := &DockerConfigEntry{}
+	err := json.Unmarshal([]byte(cmd.Payload), req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ListDockerRegistrySecretsFailed, err)
+	}
+	if !opts.Namespaces.Contain(req.Namespace) {
+		return nil, command.NewResponseError(cmd.Key, model.ListDockerRegistrySecretsFailed, fmt.Errorf("namespace %s is not managed by the agent", req.Namespace))
+	}
+	secrets, err := opts.KubeClient.ListDockerRegistrySecrets(req.Namespace)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ListDockerRegistrySecretsFailed, err)
+	}
+	list := make([]*DockerRegistrySecret, 0, len(secrets))
+	for _, secret := range secrets {
+		servers, err := registryServers(secret.Data[corev1.DockerConfigJsonKey])
+		if err != nil {
+			glog.Warningf("parse registry secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+		list = append(list, &DockerRegistrySecret{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+			Servers:   servers,
+		})
+	}
+	content, err := json.Marshal(list)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.ListDockerRegistrySecretsFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.ListDockerRegistrySecrets,
+		Payload: string(content),
 	}
+}
+
+// registryServers lists the servers of a ~/.docker/config.json file.
+func registryServers(content []byte) ([]DockerRegistryServer, error) {
+	servers := []DockerRegistryServer{}
+	dockerCfgJSON := &DockerConfigJSON{}
+	if err := json.Unmarshal(content, dockerCfgJSON); err != nil {
+		return servers, err
+	}
+	for server, entry := range dockerCfgJSON.Auths {
+		username := entry.Username
+		if username == "" {
+			username = decodeDockerConfigFieldAuth(entry.Auth)
+		}
+		servers = append(servers, DockerRegistryServer{
+			Server:   server,
+			Username: username,
+			Email:    entry.Email,
+		})
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Server < servers[j].Server
+	})
+	return servers, nil
+}
 
+// handleDockerCfgJSONContent serializes a ~/.docker/config.json file
+func handleDockerCfgJSONContent(registries []DockerConfigEntry) ([]byte, error) {
 	dockerCfgJSON := DockerConfigJSON{
-		Auths: map[string]DockerConfigEntry{server: dockercfgAuth},
+		Auths: make(map[string]DockerConfigEntry, len(registries)),
+	}
+	for _, registry := range registries {
+		if registry.Server == "" {
+			return nil, fmt.Errorf("registry server is required")
+		}
+		if _, ok := dockerCfgJSON.Auths[registry.Server]; ok {
+			return nil, fmt.Errorf("registry server %s given twice", registry.Server)
+		}
+		dockerCfgJSON.Auths[registry.Server] = DockerConfigEntry{
+			Username: registry.Username,
+			Password: registry.Password,
+			Email:    registry.Email,
+			Auth:     encodeDockerConfigFieldAuth(registry.Username, registry.Password),
+		}
 	}
 
 	return json.Marshal(dockerCfgJSON)
@@ -65,6 +203,15 @@ func encodeDockerConfigFieldAuth(username, password string) string {
 	return base64.StdEncoding.EncodeToString([]byte(fieldValue))
 }
 
+// decodeDockerConfigFieldAuth returns the username of an auth field.
+func decodeDockerConfigFieldAuth(auth string) string {
+	fieldValue, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return ""
+	}
+	return strings.SplitN(string(fieldValue), ":", 2)[0]
+}
+
 // DockerConfigJSON represents a local docker auth config file
 // for pulling images.
 type DockerConfigJSON struct {
@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/namespace"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+func TestRegistryServers(t *testing.T) {
+	content, err := handleDockerCfgJSONContent([]DockerConfigEntry{
+		{Server: "registry.example.com", Username: "ci", Password: "secret", Email: "ci@example.com"},
+		{Server: "docker.io", Username: "bot", Password: "token"},
+	})
+	assert.Nil(t, err, "no error build docker config")
+	assert.False(t, strings.Contains(string(content), `"server"`), "server written in the auth entry")
+
+	servers, err := registryServers(content)
+	assert.Nil(t, err, "no error parse docker config")
+	assert.Equal(t, []DockerRegistryServer{
+		{Server: "docker.io", Username: "bot"},
+		{Server: "registry.example.com", Username: "ci", Email: "ci@example.com"},
+	}, servers, "bad registry servers")
+
+	servers, err = registryServers([]byte(`{"auths":{"docker.io":{"auth":"Ym90OnRva2Vu"}}}`))
+	assert.Nil(t, err, "no error parse docker config")
+	assert.Equal(t, "bot", servers[0].Username, "username not decoded from auth")
+
+	_, err = handleDockerCfgJSONContent([]DockerConfigEntry{{Server: "docker.io"}, {Server: "docker.io"}})
+	assert.NotNil(t, err, "duplicated server accepted")
+}
+
+func TestRegistrySecretRefused(t *testing.T) {
+	namespaces := namespace.NewNamespaces()
+	namespaces.Add("env")
+	// refused before the cluster is touched, so no kube client is needed
+	opts := &command.Opts{Namespaces: namespaces}
+
+	for _, test := range []struct {
+		name       string
+		handle     func(context.Context, *command.Opts, *model.Packet) ([]*model.Packet, *model.Packet)
+		payload    string
+		failedType string
+	}{
+		{"create unmanaged", CreateDockerRegistrySecret, `{"name":"registry","namespace":"kube-system","server":"docker.io"}`, model.OperateDockerRegistrySecretFailed},
+		{"create without name", CreateDockerRegistrySecret, `{"namespace":"env","server":"docker.io"}`, model.OperateDockerRegistrySecretFailed},
+		{"delete unmanaged", DeleteDockerRegistrySecret, `{"name":"registry","namespace":"kube-system"}`, model.DeleteDockerRegistrySecretFailed},
+		{"delete without name", DeleteDockerRegistrySecret, `{"namespace":"env"}`, model.DeleteDockerRegistrySecretFailed},
+		{"list unmanaged", ListDockerRegistrySecrets, `{"namespace":"kube-system"}`, model.ListDockerRegistrySecretsFailed},
+	} {
+		_, resp := test.handle(context.Background(), opts, &model.Packet{Key: "env:env", Payload: test.payload})
+		assert.Equal(t, test.failedType, resp.Type, "%s not refused", test.name)
+	}
+}
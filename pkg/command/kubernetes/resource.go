@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+// ResourceRequest carries a manifest, or for deletes the object to delete.
+type ResourceRequest struct {
+	Namespace  string `json:"namespace,omitempty"`
+	Manifest   string `json:"manifest,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// DryRun has the API server validate the request without persisting it.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ApplyResource creates or replaces any namespaced object in a managed
+// namespace, answering with the objects as the API server returned them.
+func ApplyResource(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req, err := parseResourceRequest(opts, cmd)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesApplyResourceFailed, err)
+	}
+	if req.Manifest == "" {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesApplyResourceFailed, fmt.Errorf("manifest is required"))
+	}
+	objects, err := opts.KubeClient.ApplyResources(ctx, req.Namespace, req.Manifest, req.DryRun)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesApplyResourceFailed, err)
+	}
+	content, err := json.Marshal(objects)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesApplyResourceFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.KubernetesApplyResource,
+		Payload: string(content),
+	}
+}
+
+// DeleteResource deletes the objects of a manifest, or the one given by
+// apiVersion, kind and name, in a managed namespace.
+func DeleteResource(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	req, err := parseResourceRequest(opts, cmd)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesDeleteResourceFailed, err)
+	}
+	manifest := req.Manifest
+	if manifest == "" {
+		if req.APIVersion == "" || req.Kind == "" || req.Name == "" {
+			return nil, command.NewResponseError(cmd.Key, model.KubernetesDeleteResourceFailed, fmt.Errorf("either a manifest or an apiVersion, kind and name are required"))
+		}
+		object, _ := json.Marshal(map[string]interface{}{
+			"apiVersion": req.APIVersion,
+			"kind":       req.Kind,
+			"metadata":   map[string]string{"name": req.Name},
+		})
+		manifest = string(object)
+	}
+	err = opts.KubeClient.DeleteResources(ctx, req.Namespace, manifest, req.DryRun)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.KubernetesDeleteResourceFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.KubernetesDeleteResource,
+		Payload: cmd.Payload,
+	}
+}
+
+func parseResourceRequest(opts *command.Opts, cmd *model.Packet) (*ResourceRequest, error) {
+	req := &ResourceRequest{}
+	if err := json.Unmarshal([]byte(cmd.Payload), req); err != nil {
+		return nil, err
+	}
+	if req.Namespace == "" {
+		req.Namespace = cmd.Namespace()
+	}
+	if !opts.Namespaces.Contain(req.Namespace) {
+		return nil, fmt.Errorf("namespace %s is not managed by the agent", req.Namespace)
+	}
+	return req, nil
+}
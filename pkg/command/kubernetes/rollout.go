@@ -0,0 +1,173 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
+)
+
+// RestartedAtAnnotation is the pod template annotation kubectl rollout
+// restart bumps as well.
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// RolloutRequest targets one workload by kind and name, or all the
+// workloads of a release.
+type RolloutRequest struct {
+	Namespace string `json:"namespace,omitempty"`
+	Release   string `json:"release,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+type RolloutResponse struct {
+	Namespace string            `json:"namespace"`
+	Release   string            `json:"release,omitempty"`
+	Resources []RolloutResource `json:"resources"`
+}
+
+type RolloutResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// rolloutPatcher patches the named workloads of one apps/v1 kind, names
+// lists the workloads of a release.
+type rolloutPatcher struct {
+	kind      string
+	resource  string
+	namespace string
+	client    rest.Interface
+}
+
+func rolloutPatchers(clientSet kubernetes.Interface, namespace string) []*rolloutPatcher {
+	client := clientSet.AppsV1().RESTClient()
+	return []*rolloutPatcher{
+		{kind: "Deployment", resource: "deployments", namespace: namespace, client: client},
+		{kind: "StatefulSet", resource: "statefulsets", namespace: namespace, client: client},
+		{kind: "DaemonSet", resource: "daemonsets", namespace: namespace, client: client},
+	}
+}
+
+func (p *rolloutPatcher) names(selector string) ([]string, error) {
+	raw, err := p.client.Get().
+		Namespace(p.namespace).
+		Resource(p.resource).
+		VersionedParams(&v1.ListOptions{LabelSelector: selector}, scheme.ParameterCodec).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{}
+	if err := list.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	return names, nil
+}
+
+func (p *rolloutPatcher) patch(name string, data []byte) error {
+	return p.client.Patch(types.StrategicMergePatchType).
+		Namespace(p.namespace).
+		Resource(p.resource).
+		Name(name).
+		Body(data).
+		Do().
+		Error()
+}
+
+// RolloutRestart restarts the pods of workloads the way kubectl rollout
+// restart does, the rollout progress reaches DevOps service as the
+// resource_update of the workloads.
+func RolloutRestart(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	restartedAt := time.Now().Format(time.RFC3339)
+	data := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, RestartedAtAnnotation, restartedAt)
+	return rollout(opts, cmd, model.RolloutRestart, model.RolloutRestartFailed, []byte(data), "Deployment", "StatefulSet", "DaemonSet")
+}
+
+// RolloutPause pauses the rollout of Deployments.
+func RolloutPause(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	return rollout(opts, cmd, model.RolloutPause, model.RolloutPauseFailed, []byte(`{"spec":{"paused":true}}`), "Deployment")
+}
+
+// RolloutResume resumes the paused rollout of Deployments.
+func RolloutResume(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	return rollout(opts, cmd, model.RolloutResume, model.RolloutResumeFailed, []byte(`{"spec":{"paused":false}}`), "Deployment")
+}
+
+func rollout(opts *command.Opts, cmd *model.Packet, respType string, failedType string, data []byte, kinds ...string) ([]*model.Packet, *model.Packet) {
+	var req *RolloutRequest
+	err := json.Unmarshal([]byte(cmd.Payload), &req)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, failedType, err)
+	}
+	if req.Release == "" && (req.Kind == "" || req.Name == "") {
+		return nil, command.NewResponseError(cmd.Key, failedType, fmt.Errorf("either a release or a kind and a name are required"))
+	}
+
+	resp := &RolloutResponse{
+		Namespace: req.Namespace,
+		Release:   req.Release,
+		Resources: []RolloutResource{},
+	}
+	selector := fmt.Sprintf("%s=%s", model.ReleaseLabel, req.Release)
+	matched := false
+	for _, patcher := range rolloutPatchers(opts.KubeClient.GetKubeClient(), req.Namespace) {
+		if !containsKind(kinds, patcher.kind) || (req.Kind != "" && req.Kind != patcher.kind) {
+			continue
+		}
+		matched = true
+		names := []string{req.Name}
+		if req.Name == "" {
+			names, err = patcher.names(selector)
+			if err != nil {
+				return nil, command.NewResponseError(cmd.Key, failedType, err)
+			}
+		}
+		for _, name := range names {
+			if err := patcher.patch(name, data); err != nil {
+				if errors.IsNotFound(err) && req.Name == "" {
+					continue
+				}
+				return nil, command.NewResponseError(cmd.Key, failedType, fmt.Errorf("%s %s: %v", patcher.kind, name, err))
+			}
+			resp.Resources = append(resp.Resources, RolloutResource{Kind: patcher.kind, Name: name})
+		}
+	}
+	if !matched {
+		return nil, command.NewResponseError(cmd.Key, failedType, fmt.Errorf("kind %s does not support %s", req.Kind, respType))
+	}
+
+	content, err := json.Marshal(resp)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, failedType, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    respType,
+		Payload: string(content),
+	}
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -1,41 +1,165 @@
 package kubernetes
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ScalePodRequest struct {
+	// Kind defaults to Deployment.
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+	// DeploymentName is the name of old requests without a kind.
 	DeploymentName string `json:"deploymentName,omitempty"`
 	Count          int    `json:"count,omitempty"`
 	Namespace      string `json:"namespace,omitempty"`
+	// Force scales a workload an HPA targets, the HPA may scale it back.
+	Force bool `json:"force,omitempty"`
+}
+
+type ScalePodResponse struct {
+	Kind            string `json:"kind"`
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	Replicas        int32  `json:"replicas"`
+	CurrentReplicas int32  `json:"currentReplicas"`
+	Selector        string `json:"selector,omitempty"`
+	HPA             string `json:"hpa,omitempty"`
+	Warning         string `json:"warning,omitempty"`
+}
+
+// scaleClient reads and writes the scale subresource of one kind.
+type scaleClient struct {
+	get    func(name string) (*autoscaling_v1.Scale, error)
+	update func(name string, scale *autoscaling_v1.Scale) (*autoscaling_v1.Scale, error)
+}
+
+func newScaleClient(clientSet kubernetes.Interface, kind string, namespace string) (*scaleClient, error) {
+	switch kind {
+	case "Deployment":
+		deployments := clientSet.AppsV1().Deployments(namespace)
+		return &scaleClient{
+			get:    func(name string) (*autoscaling_v1.Scale, error) { return deployments.GetScale(name, v1.GetOptions{}) },
+			update: deployments.UpdateScale,
+		}, nil
+	case "StatefulSet":
+		statefulSets := clientSet.AppsV1().StatefulSets(namespace)
+		return &scaleClient{
+			get:    func(name string) (*autoscaling_v1.Scale, error) { return statefulSets.GetScale(name, v1.GetOptions{}) },
+			update: statefulSets.UpdateScale,
+		}, nil
+	case "ReplicaSet":
+		replicaSets := clientSet.AppsV1().ReplicaSets(namespace)
+		return &scaleClient{
+			get:    func(name string) (*autoscaling_v1.Scale, error) { return replicaSets.GetScale(name, v1.GetOptions{}) },
+			update: replicaSets.UpdateScale,
+		}, nil
+	case "ReplicationController":
+		controllers := clientSet.CoreV1().ReplicationControllers(namespace)
+		return &scaleClient{
+			get:    func(name string) (*autoscaling_v1.Scale, error) { return controllers.GetScale(name, v1.GetOptions{}) },
+			update: controllers.UpdateScale,
+		}, nil
+	}
+	return nil, fmt.Errorf("can not scale kind %s", kind)
 }
 
-func ScalePod(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+// ScalePod sets the replicas of a workload through its scale subresource and
+// answers with the resulting replica status.
+func ScalePod(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
 	var req *ScalePodRequest
 	err := json.Unmarshal([]byte(cmd.Payload), &req)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
 	}
+	if req.Kind == "" {
+		req.Kind = "Deployment"
+	}
+	if req.Name == "" {
+		req.Name = req.DeploymentName
+	}
+	if req.Count < 0 {
+		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, fmt.Errorf("invalid count %d", req.Count))
+	}
 
 	clientSet := opts.KubeClient.GetKubeClient()
-	s, err := clientSet.AppsV1().Deployments(req.Namespace).GetScale(req.DeploymentName, v1.GetOptions{})
+	scales, err := newScaleClient(clientSet, req.Kind, req.Namespace)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
+	}
+
+	hpa, err := findHPA(clientSet, req.Namespace, req.Kind, req.Name)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
+	}
+	resp := &ScalePodResponse{
+		Kind:      req.Kind,
+		Name:      req.Name,
+		Namespace: req.Namespace,
+	}
+	if hpa != nil {
+		resp.HPA = hpa.Name
+		resp.Warning = fmt.Sprintf("%s %s is scaled by HorizontalPodAutoscaler %s between %d and %d replicas",
+			req.Kind, req.Name, hpa.Name, minReplicas(hpa), hpa.Spec.MaxReplicas)
+		if !req.Force {
+			return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, fmt.Errorf("%s, force to scale anyway", resp.Warning))
+		}
+		glog.Warningf("force scale: %s", resp.Warning)
+	}
+
+	s, err := scales.get(req.Name)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
 	}
 
 	s.Spec.Replicas = int32(req.Count)
 
-	_, err = clientSet.AppsV1().Deployments(req.Namespace).UpdateScale(req.DeploymentName, s)
+	s, err = scales.update(req.Name, s)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
 	}
+	resp.Replicas = s.Spec.Replicas
+	resp.CurrentReplicas = s.Status.Replicas
+	resp.Selector = s.Status.Selector
+
+	content, err := json.Marshal(resp)
+	if err != nil {
+		return nil, command.NewResponseError(cmd.Key, model.OperatePodCountFailed, err)
+	}
+	return nil, &model.Packet{
+		Key:     cmd.Key,
+		Type:    model.OperatePodCountSuccess,
+		Payload: string(content),
+	}
+}
+
+// findHPA returns the HorizontalPodAutoscaler targeting a workload, if any.
+func findHPA(clientSet kubernetes.Interface, namespace string, kind string, name string) (*autoscaling_v1.HorizontalPodAutoscaler, error) {
+	hpas, err := clientSet.AutoscalingV1().HorizontalPodAutoscalers(namespace).List(v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range hpas.Items {
+		target := hpas.Items[i].Spec.ScaleTargetRef
+		if target.Kind == kind && target.Name == name {
+			return &hpas.Items[i], nil
+		}
+	}
+	return nil, nil
+}
 
-	resp := &model.Packet{
-		Key:  cmd.Key,
-		Type: model.OperatePodCountSuccess,
+func minReplicas(hpa *autoscaling_v1.HorizontalPodAutoscaler) int32 {
+	if hpa.Spec.MinReplicas == nil {
+		return 1
 	}
-	return nil, resp
+	return *hpa.Spec.MinReplicas
 }
@@ -1,13 +1,14 @@
 package kubernetes
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/util/command"
 )
 
-func CreateService(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	svc, err := opts.KubeClient.CreateOrUpdateService(cmd.Namespace(), cmd.Payload)
+func CreateService(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	svc, err := opts.KubeClient.CreateOrUpdateService(ctx, cmd.Namespace(), cmd.Payload)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.NetworkServiceFailed, err)
 	}
@@ -23,8 +24,8 @@ func CreateService(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *mod
 	return nil, resp
 }
 
-func DeleteService(opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
-	err := opts.KubeClient.DeleteService(cmd.Namespace(), cmd.Payload)
+func DeleteService(ctx context.Context, opts *command.Opts, cmd *model.Packet) ([]*model.Packet, *model.Packet) {
+	err := opts.KubeClient.DeleteService(ctx, cmd.Namespace(), cmd.Payload)
 	if err != nil {
 		return nil, command.NewResponseError(cmd.Key, model.NetworkServiceDeleteFailed, err)
 	}
@@ -119,7 +119,7 @@ func (r *ReconcileC7NHelmRelease) Reconcile(request reconcile.Request) (reconcil
 	}
 
 	// rls -> release
-	rls, err := helmClient.GetRelease(&modelhelm.GetReleaseContentRequest{ReleaseName: name})
+	rls, err := helmClient.GetRelease(context.TODO(), &modelhelm.GetReleaseContentRequest{ReleaseName: name})
 
 	if err != nil {
 		if !strings.Contains(err.Error(), helm.ErrReleaseNotFound(name).Error()) {
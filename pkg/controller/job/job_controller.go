@@ -146,7 +146,7 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 			} else if strings.TrimSpace(jobLogs) != "" {
 				responseChan <- newTestJobLogRep(instance.Labels[model.TestLabel], instance.Labels[model.ReleaseLabel], jobLogs, namespace, succeed)
 			}
-			_, err = r.args.HelmClient.DeleteRelease(&helm.DeleteReleaseRequest{ReleaseName: instance.Labels[model.ReleaseLabel]})
+			_, err = r.args.HelmClient.DeleteRelease(context.TODO(), &helm.DeleteReleaseRequest{ReleaseName: instance.Labels[model.ReleaseLabel]})
 			if err != nil {
 				glog.Error("delete release error", err)
 			}
@@ -2,6 +2,7 @@ package helm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,11 +20,11 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 	"k8s.io/helm/pkg/chartutil"
-	"k8s.io/helm/pkg/helm"
 	"k8s.io/helm/pkg/helm/environment"
 	"k8s.io/helm/pkg/hooks"
 	"k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/proto/hapi/release"
+	"k8s.io/helm/pkg/proto/hapi/services"
 	"k8s.io/helm/pkg/tiller"
 	tillerenv "k8s.io/helm/pkg/tiller/environment"
 	"k8s.io/helm/pkg/timeconv"
@@ -62,25 +63,25 @@ var (
 )
 
 type Client interface {
-	ListRelease(namespace string) ([]*Release, error)
-	ExecuteTest(request *TestReleaseRequest) (*TestReleaseResponse, error)
-	InstallRelease(request *InstallReleaseRequest) (*Release, error)
-	PreInstallRelease(request *InstallReleaseRequest) ([]*ReleaseHook, error)
-	PreUpgradeRelease(request *UpgradeReleaseRequest) ([]*ReleaseHook, error)
-	UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error)
-	RollbackRelease(request *RollbackReleaseRequest) (*Release, error)
-	DeleteRelease(request *DeleteReleaseRequest) (*Release, error)
-	StartRelease(request *StartReleaseRequest) (*StartReleaseResponse, error)
-	StopRelease(request *StopReleaseRequest) (*StopReleaseResponse, error)
-	GetReleaseContent(request *GetReleaseContentRequest) (*Release, error)
-	GetRelease(request *GetReleaseContentRequest) (*Release, error)
-	ListAgent(devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error)
-	DeleteNamespaceReleases(namespaces string) error
+	ListRelease(ctx context.Context, namespace string) ([]*Release, error)
+	ExecuteTest(ctx context.Context, request *TestReleaseRequest) (*TestReleaseResponse, error)
+	InstallRelease(ctx context.Context, request *InstallReleaseRequest) (*Release, error)
+	PreInstallRelease(ctx context.Context, request *InstallReleaseRequest) ([]*ReleaseHook, error)
+	PreUpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) ([]*ReleaseHook, error)
+	UpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) (*Release, error)
+	RollbackRelease(ctx context.Context, request *RollbackReleaseRequest) (*Release, error)
+	DeleteRelease(ctx context.Context, request *DeleteReleaseRequest) (*Release, error)
+	StartRelease(ctx context.Context, request *StartReleaseRequest) (*StartReleaseResponse, error)
+	StopRelease(ctx context.Context, request *StopReleaseRequest) (*StopReleaseResponse, error)
+	GetReleaseContent(ctx context.Context, request *GetReleaseContentRequest) (*Release, error)
+	GetRelease(ctx context.Context, request *GetReleaseContentRequest) (*Release, error)
+	ListAgent(ctx context.Context, devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error)
+	DeleteNamespaceReleases(ctx context.Context, namespaces string) error
 }
 
 type client struct {
 	config     *rest.Config
-	helmClient *helm.Client
+	tiller     *releaseService
 	kubeClient envkube.Client
 }
 
@@ -100,20 +101,20 @@ func NewClient(kubeClient envkube.Client, config *rest.Config) Client {
 	}
 
 	setupConnection()
-	helmClient := helm.NewClient(helm.Host(settings.TillerHost), helm.ConnectTimeout(settings.TillerConnectionTimeout))
 
 	return &client{
 		config:     config,
-		helmClient: helmClient,
+		tiller:     newReleaseService(settings.TillerHost, settings.TillerConnectionTimeout),
 		kubeClient: kubeClient,
 	}
 }
 
-func (c *client) ListRelease(namespace string) ([]*Release, error) {
+func (c *client) ListRelease(ctx context.Context, namespace string) ([]*Release, error) {
 	releases := make([]*Release, 0)
-	hlr, err := c.helmClient.ListReleases(helm.ReleaseListNamespace(namespace))
+	hlr, err := c.tiller.list(ctx, &services.ListReleasesRequest{Namespace: namespace})
 	if err != nil {
 		glog.Error("helm client list release error", err)
+		return nil, err
 	}
 
 	for _, hr := range hlr.Releases {
@@ -131,10 +132,10 @@ func (c *client) ListRelease(namespace string) ([]*Release, error) {
 	return releases, nil
 }
 
-func (c *client) PreInstallRelease(request *InstallReleaseRequest) ([]*ReleaseHook, error) {
+func (c *client) PreInstallRelease(ctx context.Context, request *InstallReleaseRequest) ([]*ReleaseHook, error) {
 	var releaseHooks []*ReleaseHook
 
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -142,7 +143,7 @@ func (c *client) PreInstallRelease(request *InstallReleaseRequest) ([]*ReleaseHo
 		return nil, fmt.Errorf("release %s already exist", request.ReleaseName)
 	}
 
-	chartRequested, err := getChart(request.RepoURL, request.ChartName, request.ChartVersion)
+	chartRequested, err := getChart(ctx, request.RepoURL, request.ChartName, request.ChartVersion)
 	if err != nil {
 		return nil, fmt.Errorf("load chart: %v", err)
 	}
@@ -172,8 +173,8 @@ func (c *client) PreInstallRelease(request *InstallReleaseRequest) ([]*ReleaseHo
 	return releaseHooks, nil
 }
 
-func (c *client) InstallRelease(request *InstallReleaseRequest) (*Release, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+func (c *client) InstallRelease(ctx context.Context, request *InstallReleaseRequest) (*Release, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -181,7 +182,7 @@ func (c *client) InstallRelease(request *InstallReleaseRequest) (*Release, error
 		return nil, fmt.Errorf("release %s already exist", request.ReleaseName)
 	}
 
-	chartRequested, err := getChart(request.RepoURL, request.ChartName, request.ChartVersion)
+	chartRequested, err := getChart(ctx, request.RepoURL, request.ChartName, request.ChartVersion)
 	if err != nil {
 		return nil, fmt.Errorf("load chart: %v", err)
 	}
@@ -252,23 +253,22 @@ func (c *client) InstallRelease(request *InstallReleaseRequest) (*Release, error
 	chartRequested.Templates = newTemplates
 	chartRequested.Dependencies = []*chart.Chart{}
 	chartRequested.Values.Raw = oldValues
-	installReleaseResp, err := c.helmClient.InstallReleaseFromChart(
-		chartRequested,
-		request.Namespace,
-		helm.ValueOverrides([]byte(request.Values)),
-		helm.ReleaseName(request.ReleaseName),
-	)
+	installReleaseResp, err := c.tiller.install(ctx, chartRequested, request.Namespace, request.ReleaseName, request.Values)
+	if err != nil && ctx.Err() != nil {
+		// Tiller may still be installing, the release is not cleaned up
+		return nil, fmt.Errorf("install release %s: %v", request.ReleaseName, err)
+	}
 	if err != nil {
 		newError := fmt.Errorf("install release %s: %v", request.ReleaseName, err)
 		if installReleaseResp != nil {
 			rls, err := c.getHelmRelease(installReleaseResp.GetRelease())
 			if err != nil {
-				c.DeleteRelease(&DeleteReleaseRequest{ReleaseName: request.ReleaseName})
+				c.DeleteRelease(context.Background(), &DeleteReleaseRequest{ReleaseName: request.ReleaseName})
 				return nil, err
 			}
 			return rls, newError
 		}
-		c.DeleteRelease(&DeleteReleaseRequest{ReleaseName: request.ReleaseName})
+		c.DeleteRelease(context.Background(), &DeleteReleaseRequest{ReleaseName: request.ReleaseName})
 		return nil, newError
 	}
 	rls, err := c.getHelmRelease(installReleaseResp.GetRelease())
@@ -309,9 +309,9 @@ func getCertManagerIssuerData() string {
 	return data.String()
 }
 
-func (c *client) ExecuteTest(request *TestReleaseRequest) (*TestReleaseResponse, error) {
+func (c *client) ExecuteTest(ctx context.Context, request *TestReleaseRequest) (*TestReleaseResponse, error) {
 
-	chartRequested, err := getChart(request.RepoURL, request.ChartName, request.ChartVersion)
+	chartRequested, err := getChart(ctx, request.RepoURL, request.ChartName, request.ChartVersion)
 	if err != nil {
 		return nil, fmt.Errorf("load chart: %v", err)
 	}
@@ -355,12 +355,11 @@ func (c *client) ExecuteTest(request *TestReleaseRequest) (*TestReleaseResponse,
 
 	chartRequested.Templates = newTemplates
 	chartRequested.Dependencies = []*chart.Chart{}
-	installReleaseResp, err := c.helmClient.InstallReleaseFromChart(
-		chartRequested,
-		testNamespace,
-		helm.ValueOverrides([]byte(request.Values)),
-		helm.ReleaseName(request.ReleaseName),
-	)
+	installReleaseResp, err := c.tiller.install(ctx, chartRequested, testNamespace, request.ReleaseName, request.Values)
+	if err != nil && ctx.Err() != nil {
+		// Tiller may still be installing, the release is not cleaned up
+		return nil, fmt.Errorf("execute test release %s: %v", request.ReleaseName, err)
+	}
 
 	resp := &TestReleaseResponse{ReleaseName: request.ReleaseName}
 	if err != nil {
@@ -368,13 +367,13 @@ func (c *client) ExecuteTest(request *TestReleaseRequest) (*TestReleaseResponse,
 		if installReleaseResp != nil {
 			_, err := c.getHelmRelease(installReleaseResp.GetRelease())
 			if err != nil {
-				c.DeleteRelease(&DeleteReleaseRequest{ReleaseName: request.ReleaseName})
+				c.DeleteRelease(context.Background(), &DeleteReleaseRequest{ReleaseName: request.ReleaseName})
 				return nil, err
 			}
 
 			return resp, newError
 		}
-		c.DeleteRelease(&DeleteReleaseRequest{ReleaseName: request.ReleaseName})
+		c.DeleteRelease(context.Background(), &DeleteReleaseRequest{ReleaseName: request.ReleaseName})
 		return nil, newError
 	}
 	_, err = c.getHelmRelease(installReleaseResp.GetRelease())
@@ -492,10 +491,10 @@ func (c *client) getHelmReleaseNoResource(release *release.Release) (*Release, e
 	return rls, nil
 }
 
-func (c *client) PreUpgradeRelease(request *UpgradeReleaseRequest) ([]*ReleaseHook, error) {
+func (c *client) PreUpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) ([]*ReleaseHook, error) {
 	var releaseHooks []*ReleaseHook
 
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -509,10 +508,10 @@ func (c *client) PreUpgradeRelease(request *UpgradeReleaseRequest) ([]*ReleaseHo
 			Namespace:        request.Namespace,
 			ImagePullSecrets: request.ImagePullSecrets,
 		}
-		return c.PreInstallRelease(installReq)
+		return c.PreInstallRelease(ctx, installReq)
 	}
 
-	chartRequested, err := getChart(request.RepoURL, request.ChartName, request.ChartVersion)
+	chartRequested, err := getChart(ctx, request.RepoURL, request.ChartName, request.ChartVersion)
 	if err != nil {
 		return nil, fmt.Errorf("load chart: %v", err)
 	}
@@ -543,8 +542,8 @@ func (c *client) PreUpgradeRelease(request *UpgradeReleaseRequest) ([]*ReleaseHo
 	return releaseHooks, nil
 }
 
-func (c *client) UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+func (c *client) UpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) (*Release, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -558,14 +557,14 @@ func (c *client) UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error
 			Namespace:        request.Namespace,
 			ImagePullSecrets: request.ImagePullSecrets,
 		}
-		installResp, err := c.InstallRelease(installReq)
+		installResp, err := c.InstallRelease(ctx, installReq)
 		if err != nil {
 			return nil, err
 		}
 		return installResp, nil
 	}
 
-	chartRequested, err := getChart(request.RepoURL, request.ChartName, request.ChartVersion)
+	chartRequested, err := getChart(ctx, request.RepoURL, request.ChartName, request.ChartVersion)
 	if err != nil {
 		return nil, fmt.Errorf("load chart: %v", err)
 	}
@@ -611,11 +610,7 @@ func (c *client) UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error
 		chartRequested.Dependencies = []*chart.Chart{}
 	}
 
-	updateReleaseResp, err := c.helmClient.UpdateReleaseFromChart(
-		request.ReleaseName,
-		chartRequested,
-		helm.UpdateValueOverrides([]byte(request.Values)),
-	)
+	updateReleaseResp, err := c.tiller.update(ctx, request.ReleaseName, chartRequested, request.Values)
 	if err != nil {
 		newErr := fmt.Errorf("update release %s: %v", request.ReleaseName, err)
 		if updateReleaseResp != nil {
@@ -636,10 +631,8 @@ func (c *client) UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error
 	return rls, nil
 }
 
-func (c *client) RollbackRelease(request *RollbackReleaseRequest) (*Release, error) {
-	rollbackReleaseResp, err := c.helmClient.RollbackRelease(
-		request.ReleaseName,
-		helm.RollbackVersion(int32(request.Version)))
+func (c *client) RollbackRelease(ctx context.Context, request *RollbackReleaseRequest) (*Release, error) {
+	rollbackReleaseResp, err := c.tiller.rollback(ctx, request.ReleaseName, int32(request.Version))
 	if err != nil {
 		return nil, fmt.Errorf("rollback release %s: %v", request.ReleaseName, err)
 	}
@@ -651,11 +644,8 @@ func (c *client) RollbackRelease(request *RollbackReleaseRequest) (*Release, err
 	return rls, nil
 }
 
-func (c *client) DeleteRelease(request *DeleteReleaseRequest) (*Release, error) {
-	deleteReleaseResp, err := c.helmClient.DeleteRelease(
-		request.ReleaseName,
-		helm.DeletePurge(true),
-	)
+func (c *client) DeleteRelease(ctx context.Context, request *DeleteReleaseRequest) (*Release, error) {
+	deleteReleaseResp, err := c.tiller.uninstall(ctx, request.ReleaseName)
 	if err != nil {
 		return nil, fmt.Errorf("delete release %s: %v", request.ReleaseName, err)
 	}
@@ -666,8 +656,8 @@ func (c *client) DeleteRelease(request *DeleteReleaseRequest) (*Release, error)
 	return rls, nil
 }
 
-func (c *client) StopRelease(request *StopReleaseRequest) (*StopReleaseResponse, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+func (c *client) StopRelease(ctx context.Context, request *StopReleaseRequest) (*StopReleaseResponse, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -675,7 +665,7 @@ func (c *client) StopRelease(request *StopReleaseRequest) (*StopReleaseResponse,
 		return nil, fmt.Errorf("release %s not exist", request.ReleaseName)
 	}
 
-	err = c.kubeClient.StopResources(request.Namespace, releaseContentResp.Release.Manifest)
+	err = c.kubeClient.StopResources(ctx, request.Namespace, releaseContentResp.Release.Manifest)
 	if err != nil {
 		return nil, fmt.Errorf("get resource: %v", err)
 	}
@@ -685,8 +675,8 @@ func (c *client) StopRelease(request *StopReleaseRequest) (*StopReleaseResponse,
 	return resp, nil
 }
 
-func (c *client) StartRelease(request *StartReleaseRequest) (*StartReleaseResponse, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName)
+func (c *client) StartRelease(ctx context.Context, request *StartReleaseRequest) (*StartReleaseResponse, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, 0)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -694,7 +684,7 @@ func (c *client) StartRelease(request *StartReleaseRequest) (*StartReleaseRespon
 		return nil, fmt.Errorf("release %s not exist", request.ReleaseName)
 	}
 
-	err = c.kubeClient.StartResources(request.Namespace, releaseContentResp.Release.Manifest)
+	err = c.kubeClient.StartResources(ctx, request.Namespace, releaseContentResp.Release.Manifest)
 	if err != nil {
 		return nil, fmt.Errorf("get resource: %v", err)
 	}
@@ -704,8 +694,8 @@ func (c *client) StartRelease(request *StartReleaseRequest) (*StartReleaseRespon
 	return resp, nil
 }
 
-func (c *client) ListAgent(devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error) {
-	listReleasesRsp, err := c.helmClient.ListReleases(helm.ReleaseListStatuses([]release.Status_Code{}))
+func (c *client) ListAgent(ctx context.Context, devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error) {
+	listReleasesRsp, err := c.tiller.list(ctx, &services.ListReleasesRequest{})
 	upgradeInfo := &model.UpgradeInfo{
 		Envs: []model.OldEnv{},
 	}
@@ -732,7 +722,7 @@ func (c *client) ListAgent(devConnectUrl string) (*model.UpgradeInfo, *CertManag
 					ReleaseName: rls.Name,
 					Namespace:   rls.Namespace,
 				}
-				rsp, err := c.StopRelease(stopRls)
+				rsp, err := c.StopRelease(ctx, stopRls)
 				if err == nil {
 					glog.Infof("stop old agent %s succeed", rsp.ReleaseName)
 				} else {
@@ -833,8 +823,8 @@ func (c *client) renderManifests(
 	return hooks, b, nil
 }
 
-func (c *client) GetReleaseContent(request *GetReleaseContentRequest) (*Release, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName, helm.ContentReleaseVersion(request.Version))
+func (c *client) GetReleaseContent(ctx context.Context, request *GetReleaseContentRequest) (*Release, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, request.Version)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
@@ -848,22 +838,25 @@ func (c *client) GetReleaseContent(request *GetReleaseContentRequest) (*Release,
 	return rls, nil
 }
 
-func (c *client) DeleteNamespaceReleases(namespaces string) error {
+func (c *client) DeleteNamespaceReleases(ctx context.Context, namespaces string) error {
 
-	rlss, err := c.helmClient.ListReleases(helm.ReleaseListNamespace(namespaces))
+	rlss, err := c.tiller.list(ctx, &services.ListReleasesRequest{Namespace: namespaces})
 	if err != nil {
 		glog.Errorf("delete ns release failed %v", err)
 		return err
 	}
 	for _, rls := range rlss.Releases {
-		c.helmClient.DeleteRelease(rls.Name, helm.DeletePurge(true))
+		releaseName := rls.Name
+		if _, err := c.tiller.uninstall(ctx, releaseName); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
 	}
 	return nil
 
 }
 
-func (c *client) GetRelease(request *GetReleaseContentRequest) (*Release, error) {
-	releaseContentResp, err := c.helmClient.ReleaseContent(request.ReleaseName, helm.ContentReleaseVersion(request.Version))
+func (c *client) GetRelease(ctx context.Context, request *GetReleaseContentRequest) (*Release, error) {
+	releaseContentResp, err := c.tiller.content(ctx, request.ReleaseName, request.Version)
 	if err != nil && !strings.Contains(err.Error(), ErrReleaseNotFound(request.ReleaseName).Error()) {
 		return nil, err
 	}
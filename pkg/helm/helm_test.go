@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/command/helm"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/git"
@@ -72,72 +73,72 @@ type helmClientTest struct {
 	mock.Mock
 }
 
-func (c *helmClientTest) ListRelease(namespace string) ([]*Release, error) {
+func (c *helmClientTest) ListRelease(ctx context.Context, namespace string) ([]*Release, error) {
 	args := c.Called(namespace)
 	return args.Get(0).([]*Release), args.Error(0)
 }
 
-func (c *helmClientTest) ExecuteTest(request *TestReleaseRequest) (*TestReleaseResponse, error) {
+func (c *helmClientTest) ExecuteTest(ctx context.Context, request *TestReleaseRequest) (*TestReleaseResponse, error) {
 	args := c.Called(request)
 	return args.Get(0).(*TestReleaseResponse), args.Error(1)
 }
 
-func (c *helmClientTest) InstallRelease(request *InstallReleaseRequest) (*Release, error) {
+func (c *helmClientTest) InstallRelease(ctx context.Context, request *InstallReleaseRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) PreInstallRelease(request *InstallReleaseRequest) ([]*ReleaseHook, error) {
+func (c *helmClientTest) PreInstallRelease(ctx context.Context, request *InstallReleaseRequest) ([]*ReleaseHook, error) {
 	args := c.Called(request)
 	return args.Get(0).([]*ReleaseHook), args.Error(1)
 }
 
-func (c *helmClientTest) PreUpgradeRelease(request *UpgradeReleaseRequest) ([]*ReleaseHook, error) {
+func (c *helmClientTest) PreUpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) ([]*ReleaseHook, error) {
 	args := c.Called(request)
 	return args.Get(0).([]*ReleaseHook), args.Error(1)
 }
 
-func (c *helmClientTest) UpgradeRelease(request *UpgradeReleaseRequest) (*Release, error) {
+func (c *helmClientTest) UpgradeRelease(ctx context.Context, request *UpgradeReleaseRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) RollbackRelease(request *RollbackReleaseRequest) (*Release, error) {
+func (c *helmClientTest) RollbackRelease(ctx context.Context, request *RollbackReleaseRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) DeleteRelease(request *DeleteReleaseRequest) (*Release, error) {
+func (c *helmClientTest) DeleteRelease(ctx context.Context, request *DeleteReleaseRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) StartRelease(request *StartReleaseRequest) (*StartReleaseResponse, error) {
+func (c *helmClientTest) StartRelease(ctx context.Context, request *StartReleaseRequest) (*StartReleaseResponse, error) {
 	args := c.Called(request)
 	return args.Get(0).(*StartReleaseResponse), args.Error(1)
 }
 
-func (c *helmClientTest) StopRelease(request *StopReleaseRequest) (*StopReleaseResponse, error) {
+func (c *helmClientTest) StopRelease(ctx context.Context, request *StopReleaseRequest) (*StopReleaseResponse, error) {
 	args := c.Called(request)
 	return args.Get(0).(*StopReleaseResponse), args.Error(1)
 }
 
-func (c *helmClientTest) GetReleaseContent(request *GetReleaseContentRequest) (*Release, error) {
+func (c *helmClientTest) GetReleaseContent(ctx context.Context, request *GetReleaseContentRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) GetRelease(request *GetReleaseContentRequest) (*Release, error) {
+func (c *helmClientTest) GetRelease(ctx context.Context, request *GetReleaseContentRequest) (*Release, error) {
 	args := c.Called(request)
 	return args.Get(0).(*Release), args.Error(1)
 }
 
-func (c *helmClientTest) ListAgent(devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error) {
+func (c *helmClientTest) ListAgent(ctx context.Context, devConnectUrl string) (*model.UpgradeInfo, *CertManagerInfo, error) {
 
 	return &model.UpgradeInfo{}, &CertManagerInfo{}, nil
 }
 
-func (c *helmClientTest) DeleteNamespaceReleases(namespaces string) error {
+func (c *helmClientTest) DeleteNamespaceReleases(ctx context.Context, namespaces string) error {
 	return nil
 }
 
@@ -170,7 +171,7 @@ func TestPreInstallHelmRelease(t *testing.T) {
 		GitConfig:  git.Config{},
 		HelmClient: helmClient,
 	}
-	newCmds, resp := helm.PreInstallHelmRelease(opts, cmd)
+	newCmds, resp := helm.PreInstallHelmRelease(context.TODO(), opts, cmd)
 
 	assert.Equal(t, len(newCmds), 1, "only one new command")
 	assert.Equal(t, model.HelmInstallRelease, newCmds[0].Type, "get install command")
@@ -0,0 +1,164 @@
+package helm
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/proto/hapi/services"
+	"k8s.io/helm/pkg/version"
+)
+
+// maxMsgSize is the message size limit of the helm client, releases with
+// many resources do not fit the 4MB grpc default.
+const maxMsgSize = 1024 * 1024 * 20
+
+// releaseService calls the Tiller release service under the context of the
+// command. The helm 2 client builds its own context for every call, so a
+// command cancelled or timed out would leave its call running.
+type releaseService struct {
+	host           string
+	connectTimeout time.Duration
+}
+
+func newReleaseService(host string, connectTimeout int64) *releaseService {
+	return &releaseService{
+		host:           host,
+		connectTimeout: time.Duration(connectTimeout) * time.Second,
+	}
+}
+
+// call connects to Tiller and runs fn, the connection and the call are
+// aborted once ctx is done.
+func (s *releaseService) call(ctx context.Context, fn func(ctx context.Context, rlc services.ReleaseServiceClient) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, s.connectTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, s.host,
+		grpc.WithBlock(),
+		grpc.WithInsecure(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time: 30 * time.Second,
+		}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("x-helm-api-client", version.GetVersion()))
+	return fn(ctx, services.NewReleaseServiceClient(conn))
+}
+
+func (s *releaseService) install(ctx context.Context, ch *chart.Chart, namespace string, name string, values string) (*services.InstallReleaseResponse, error) {
+	config := &chart.Config{Raw: values}
+	if err := chartutil.ProcessRequirementsEnabled(ch, config); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessRequirementsImportValues(ch); err != nil {
+		return nil, err
+	}
+	var resp *services.InstallReleaseResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) (err error) {
+		resp, err = rlc.InstallRelease(ctx, &services.InstallReleaseRequest{
+			Chart:     ch,
+			Values:    config,
+			Name:      name,
+			Namespace: namespace,
+		})
+		return err
+	})
+	return resp, err
+}
+
+func (s *releaseService) update(ctx context.Context, name string, ch *chart.Chart, values string) (*services.UpdateReleaseResponse, error) {
+	config := &chart.Config{Raw: values}
+	if err := chartutil.ProcessRequirementsEnabled(ch, config); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessRequirementsImportValues(ch); err != nil {
+		return nil, err
+	}
+	var resp *services.UpdateReleaseResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) (err error) {
+		resp, err = rlc.UpdateRelease(ctx, &services.UpdateReleaseRequest{
+			Name:   name,
+			Chart:  ch,
+			Values: config,
+		})
+		return err
+	})
+	return resp, err
+}
+
+func (s *releaseService) rollback(ctx context.Context, name string, version int32) (*services.RollbackReleaseResponse, error) {
+	var resp *services.RollbackReleaseResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) (err error) {
+		resp, err = rlc.RollbackRelease(ctx, &services.RollbackReleaseRequest{
+			Name:    name,
+			Version: version,
+		})
+		return err
+	})
+	return resp, err
+}
+
+// uninstall deletes a release and purges its history.
+func (s *releaseService) uninstall(ctx context.Context, name string) (*services.UninstallReleaseResponse, error) {
+	var resp *services.UninstallReleaseResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) (err error) {
+		resp, err = rlc.UninstallRelease(ctx, &services.UninstallReleaseRequest{
+			Name:  name,
+			Purge: true,
+		})
+		return err
+	})
+	return resp, err
+}
+
+// content returns a release at version, or its latest version if 0.
+func (s *releaseService) content(ctx context.Context, name string, version int32) (*services.GetReleaseContentResponse, error) {
+	var resp *services.GetReleaseContentResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) (err error) {
+		resp, err = rlc.GetReleaseContent(ctx, &services.GetReleaseContentRequest{
+			Name:    name,
+			Version: version,
+		})
+		return err
+	})
+	return resp, err
+}
+
+// list returns the releases matching req, Tiller streams them in batches.
+func (s *releaseService) list(ctx context.Context, req *services.ListReleasesRequest) (*services.ListReleasesResponse, error) {
+	var resp *services.ListReleasesResponse
+	err := s.call(ctx, func(ctx context.Context, rlc services.ReleaseServiceClient) error {
+		stream, err := rlc.ListReleases(ctx, req)
+		if err != nil {
+			return err
+		}
+		for {
+			r, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if resp == nil {
+				resp = r
+				continue
+			}
+			resp.Releases = append(resp.Releases, r.GetReleases()...)
+		}
+	})
+	return resp, err
+}
@@ -5,6 +5,7 @@
 package helm
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -44,6 +45,19 @@ type kindSorter struct {
 }
 
 func getChart(
+	ctx context.Context,
+	repoURL string,
+	chartName string,
+	chartVersion string) (*chart.Chart, error) {
+	// the chart downloader takes no context, downloads are bounded by its
+	// http timeouts
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return downloadChart(repoURL, chartName, chartVersion)
+}
+
+func downloadChart(
 	repoURL string,
 	chartName string,
 	chartVersion string) (*chart.Chart, error) {
@@ -42,18 +42,27 @@ import (
 type Client interface {
 	DeleteJob(namespace string, name string) error
 	LogsForJob(namespace string, name string, jobLabel string) (string, string, error)
-	CreateOrUpdateService(namespace string, serviceStr string) (*core_v1.Service, error)
-	CreateOrUpdateIngress(namespace string, ingressStr string) (*ext_v1beta1.Ingress, error)
+	CreateOrUpdateService(ctx context.Context, namespace string, serviceStr string) (*core_v1.Service, error)
+	CreateOrUpdateIngress(ctx context.Context, namespace string, ingressStr string) (*ext_v1beta1.Ingress, error)
 	//todo:remove
 	GetClientSet() (internalclientset.Interface, error)
 	//---
 	GetDiscoveryClient() (discovery.DiscoveryInterface, error)
-	DeleteService(namespace string, name string) error
-	DeleteIngress(namespace string, name string) error
-	StartResources(namespace string, manifest string) error
-	StopResources(namespace string, manifest string) error
-	GetLogs(namespace string, pod string, container string) (io.ReadCloser, error)
-	Exec(namespace string, podName string, containerName string, local io.ReadWriter) error
+	DeleteService(ctx context.Context, namespace string, name string) error
+	DeleteIngress(ctx context.Context, namespace string, name string) error
+	StartResources(ctx context.Context, namespace string, manifest string) error
+	StopResources(ctx context.Context, namespace string, manifest string) error
+	GetLogs(ctx context.Context, namespace string, pod string, options *core_v1.PodLogOptions) (io.ReadCloser, error)
+	GetSelectorLogs(ctx context.Context, namespace string, selector string, options *core_v1.PodLogOptions) (io.ReadCloser, error)
+	Exec(ctx context.Context, opts *ExecOptions, local io.ReadWriter) error
+	PortForward(ctx context.Context, namespace string, podName string, port int, local io.ReadWriter) error
+	CopyFrom(ctx context.Context, opts *CopyOptions, w io.Writer) (int64, error)
+	CopyTo(ctx context.Context, opts *CopyOptions, r io.Reader) (int64, error)
+	GetReadiness(namespace string, manifest string) ([]*ResourceReadiness, error)
+	ApplyResources(ctx context.Context, namespace string, manifest string, dryRun bool) ([]runtime.Object, error)
+	DeleteResources(ctx context.Context, namespace string, manifest string, dryRun bool) error
+	GetResource(namespace string, apiVersion string, kind string, name string) (*unstructured.Unstructured, error)
+	GetResourceEvents(obj *unstructured.Unstructured) ([]core_v1.Event, error)
 	LabelObjects(namespace string, imagePullSecret []core_v1.LocalObjectReference, manifest string, releaseName string, app string, version string) (*bytes.Buffer, error)
 	LabelTestObjects(namespace string, imagePullSecret []core_v1.LocalObjectReference, manifest string, releaseName string, app string, version string, label string) (*bytes.Buffer, error)
 	LabelRepoObj(namespace, manifest, version string, commit string) (*bytes.Buffer, error)
@@ -65,7 +74,10 @@ type Client interface {
 	GetC7nHelmRelease(namespace string, releaseName string) (*v1alpha1.C7NHelmRelease, error)
 	GetKubeClient() *kubernetes.Clientset
 	IsReleaseJobRun(namespace, releaseName string) bool
-	CreateOrUpdateDockerRegistrySecret(namespace string, secret *core_v1.Secret) (*core_v1.Secret, error)
+	CreateOrUpdateDockerRegistrySecret(ctx context.Context, namespace string, secret *core_v1.Secret) (*core_v1.Secret, error)
+	DeleteDockerRegistrySecret(ctx context.Context, namespace string, name string) error
+	ListDockerRegistrySecrets(namespace string) ([]core_v1.Secret, error)
+	SetImagePullSecret(namespace string, serviceAccount string, secretName string, present bool) error
 	BuildUnstructured(namespace string, manifest string) (Result, error)
 	//todo: delete follow func
 	GetSelectRelationPod(info *resource.Info, objPods map[string][]core_v1.Pod) (map[string][]core_v1.Pod, error)
@@ -222,12 +234,15 @@ func (c *client) LogsForJob(namespace string, name string, jobLabel string) (str
 	return buf.String(), jobStatus, nil
 }
 
-func (c *client) CreateOrUpdateService(namespace string, serviceStr string) (*core_v1.Service, error) {
+func (c *client) CreateOrUpdateService(ctx context.Context, namespace string, serviceStr string) (*core_v1.Service, error) {
 	svc := &core_v1.Service{}
 	err := json.Unmarshal([]byte(serviceStr), svc)
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	oldService, err := c.client.CoreV1().Services(namespace).Get(svc.Name, meta_v1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -235,6 +250,9 @@ func (c *client) CreateOrUpdateService(namespace string, serviceStr string) (*co
 		}
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	svc.ResourceVersion = oldService.ResourceVersion
 	svc.Spec.ClusterIP = oldService.Spec.ClusterIP
 	return c.client.CoreV1().Services(namespace).Update(svc)
@@ -353,7 +371,7 @@ func (c *client) DeleteNamespace(namespace string) error {
 	return nil
 }
 
-func (c *client) CreateOrUpdateIngress(namespace string, ingressStr string) (*ext_v1beta1.Ingress, error) {
+func (c *client) CreateOrUpdateIngress(ctx context.Context, namespace string, ingressStr string) (*ext_v1beta1.Ingress, error) {
 	client, err := c.KubernetesClientSet()
 	if err != nil {
 		return nil, err
@@ -363,16 +381,25 @@ func (c *client) CreateOrUpdateIngress(namespace string, ingressStr string) (*ex
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if _, err := client.ExtensionsV1beta1().Ingresses(namespace).Get(ing.Name, meta_v1.GetOptions{}); err != nil {
 		if errors.IsNotFound(err) {
 			return client.ExtensionsV1beta1().Ingresses(namespace).Create(ing)
 		}
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return client.ExtensionsV1beta1().Ingresses(namespace).Update(ing)
 }
 
-func (c *client) DeleteService(namespace string, name string) error {
+func (c *client) DeleteService(ctx context.Context, namespace string, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	client, err := c.KubernetesClientSet()
 	if err != nil {
 		return err
@@ -380,7 +407,10 @@ func (c *client) DeleteService(namespace string, name string) error {
 	return client.CoreV1().Services(namespace).Delete(name, &meta_v1.DeleteOptions{})
 }
 
-func (c *client) DeleteIngress(namespace string, name string) error {
+func (c *client) DeleteIngress(ctx context.Context, namespace string, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	client, err := c.KubernetesClientSet()
 	if err != nil {
 		return err
@@ -388,7 +418,7 @@ func (c *client) DeleteIngress(namespace string, name string) error {
 	return client.ExtensionsV1beta1().Ingresses(namespace).Delete(name, &meta_v1.DeleteOptions{})
 }
 
-func (c *client) StopResources(namespace string, manifest string) error {
+func (c *client) StopResources(ctx context.Context, namespace string, manifest string) error {
 	result, err := c.BuildUnstructured(namespace, manifest)
 	if err != nil {
 		return fmt.Errorf("build unstructured: %v", err)
@@ -396,6 +426,9 @@ func (c *client) StopResources(namespace string, manifest string) error {
 
 	clientSet := c.GetKubeClient()
 	for _, info := range result {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		mapping := info.ResourceMapping()
 		gr := mapping.Resource.GroupResource()
 		scaleClient := scale.New(clientSet.RESTClient(), nil, nil, nil)
@@ -415,12 +448,15 @@ func (c *client) StopResources(namespace string, manifest string) error {
 	return nil
 }
 
-func (c *client) StartResources(namespace string, manifest string) error {
+func (c *client) StartResources(ctx context.Context, namespace string, manifest string) error {
 	result, err := c.BuildUnstructured(namespace, manifest)
 	if err != nil {
 		return fmt.Errorf("build unstructured: %v", err)
 	}
 	for _, info := range result {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		_, err := resource.NewHelper(info.Client, info.Mapping).Replace(info.Namespace, info.Name, true, info.Object)
 		if err != nil {
 			glog.V(2).Infof("replace: %v", err)
@@ -429,28 +465,46 @@ func (c *client) StartResources(namespace string, manifest string) error {
 	return nil
 }
 
-func (c *client) GetLogs(namespace string, pod string, containerName string) (io.ReadCloser, error) {
-	var tailLinesDefault int64 = 1000
-	req := c.client.CoreV1().Pods(namespace).GetLogs(
-		pod,
-		&core_v1.PodLogOptions{
-			Follow:    true,
-			Container: containerName,
-			TailLines: &tailLinesDefault,
-		},
-	)
-	readCloser, err := req.Stream()
-	if err != nil {
-		return nil, err
+func (c *client) Exec(ctx context.Context, opts *ExecOptions, local io.ReadWriter) error {
+	if err := c.checkExecPod(opts.Namespace, opts.PodName); err != nil {
+		return err
 	}
-	return readCloser, nil
-}
 
-func (c *client) Exec(namespace string, podName string, containerName string, local io.ReadWriter) error {
-	config, err := c.ToRESTConfig()
-	if err != nil {
-		return err
+	// without a command, open the first shell the container has
+	commands := [][]string{opts.Command}
+	if len(opts.Command) == 0 {
+		commands = nil
+		for _, testShell := range []string{"bash", "sh", "powershell", "cmd"} {
+			commands = append(commands, []string{testShell})
+		}
 	}
+	for _, cmd := range commands {
+		// the pipe was closed, do not try the next shell on a dead stream
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		streamOptions := remotecommand.StreamOptions{
+			Stdin:  local,
+			Stdout: local,
+			Stderr: local,
+			Tty:    opts.TTY,
+		}
+		if opts.TTY && opts.TerminalSizeQueue != nil {
+			streamOptions.TerminalSizeQueue = opts.TerminalSizeQueue
+		}
+		err := c.execStream(opts.Namespace, opts.PodName, opts.ContainerName, cmd, streamOptions)
+		if err == nil {
+			return nil
+		}
+		if len(opts.Command) > 0 {
+			return err
+		}
+	}
+	glog.Errorf("no support command")
+	return nil
+}
+
+func (c *client) checkExecPod(namespace string, podName string) error {
 	pod, err := c.client.CoreV1().Pods(namespace).Get(podName, meta_v1.GetOptions{})
 	if err != nil {
 		glog.Errorf("can not find pod %s :%v", podName, err)
@@ -459,40 +513,34 @@ func (c *client) Exec(namespace string, podName string, containerName string, lo
 	if pod.Status.Phase == core_v1.PodSucceeded || pod.Status.Phase == core_v1.PodFailed {
 		return fmt.Errorf("cannot exec into a container in a completed pod; current phase is %s", pod.Status.Phase)
 	}
+	return nil
+}
 
-	validShells := []string{"bash", "sh", "powershell", "cmd"}
-	for _, testShell := range validShells {
-		cmd := []string{testShell}
-		req := c.client.CoreV1().RESTClient().Post().
-			Resource("pods").
-			Name(podName).
-			Namespace(namespace).SubResource("exec").
-			Param("container", containerName)
-		req.VersionedParams(&core_v1.PodExecOptions{
-			Stdin:     true,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       true,
-			Container: containerName,
-			Command:   cmd,
-		}, legacyscheme.ParameterCodec)
-
-		exec, err := remotecommand.NewSPDYExecutor(config, http.MethodPost, req.URL())
-		if err == nil {
-			err = exec.Stream(remotecommand.StreamOptions{
-				Stdin:  local,
-				Stdout: local,
-				Stderr: local,
-				Tty:    true,
-			})
-			if err == nil {
-				return nil
-			}
-
-		}
+// execStream runs cmd in a container with the streams given.
+func (c *client) execStream(namespace string, podName string, containerName string, cmd []string, streams remotecommand.StreamOptions) error {
+	config, err := c.ToRESTConfig()
+	if err != nil {
+		return err
 	}
-	glog.Errorf("no support command")
-	return nil
+	req := c.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).SubResource("exec").
+		Param("container", containerName)
+	req.VersionedParams(&core_v1.PodExecOptions{
+		Stdin:     streams.Stdin != nil,
+		Stdout:    streams.Stdout != nil,
+		Stderr:    streams.Stderr != nil,
+		TTY:       streams.Tty,
+		Container: containerName,
+		Command:   cmd,
+	}, legacyscheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(config, http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+	return exec.Stream(streams)
 }
 
 func (c *client) GetSelectRelationPod(info *resource.Info, objPods map[string][]core_v1.Pod) (map[string][]core_v1.Pod, error) {
@@ -894,7 +942,10 @@ func labelTestObject(info *resource.Info,
 	return addLabel(imagePullSecret, info, version, releaseName, app, label, true)
 }
 
-func (c *client) CreateOrUpdateDockerRegistrySecret(namespace string, secret *core_v1.Secret) (*core_v1.Secret, error) {
+func (c *client) CreateOrUpdateDockerRegistrySecret(ctx context.Context, namespace string, secret *core_v1.Secret) (*core_v1.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	_, err := c.client.CoreV1().Secrets(namespace).Get(secret.Name, meta_v1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -902,5 +953,8 @@ func (c *client) CreateOrUpdateDockerRegistrySecret(namespace string, secret *co
 		}
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return c.client.CoreV1().Secrets(namespace).Update(secret)
 }
@@ -0,0 +1,187 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// CopyMaxBytes caps the tar archives copied into and out of containers.
+var CopyMaxBytes int64 = 100 << 20
+
+// maxStderr caps the tar error output kept for the error message.
+const maxStderr = 4096
+
+var errCopyTooLarge = errors.New("archive too large")
+
+// CopyOptions points at a file or directory in a container.
+type CopyOptions struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// Path is absolute, it is archived by CopyFrom and is the directory
+	// the archive is extracted in by CopyTo.
+	Path string
+}
+
+// CopyFrom writes a tar archive of the path to w, as kubectl cp does, and
+// returns its size.
+func (c *client) CopyFrom(ctx context.Context, opts *CopyOptions, w io.Writer) (int64, error) {
+	if err := ValidateCopyPath(opts.Path); err != nil {
+		return 0, err
+	}
+	if err := c.checkExecPod(opts.Namespace, opts.PodName); err != nil {
+		return 0, err
+	}
+	stdout := &limitedWriter{w: w, max: CopyMaxBytes}
+	stderr := &stderrBuffer{}
+	cmd := []string{"tar", "cf", "-", "-C", path.Dir(opts.Path), path.Base(opts.Path)}
+	err := c.withContext(ctx, func() error {
+		return c.execStream(opts.Namespace, opts.PodName, opts.ContainerName, cmd, remotecommand.StreamOptions{
+			Stdout: stdout,
+			Stderr: stderr,
+		})
+	})
+	n := stdout.count()
+	return n, copyError(err, n > CopyMaxBytes, stderr)
+}
+
+// CopyTo extracts the tar archive read from r in the path, which must be
+// a directory, and returns the size of the archive.
+func (c *client) CopyTo(ctx context.Context, opts *CopyOptions, r io.Reader) (int64, error) {
+	if err := ValidateCopyPath(opts.Path); err != nil {
+		return 0, err
+	}
+	if err := c.checkExecPod(opts.Namespace, opts.PodName); err != nil {
+		return 0, err
+	}
+	stdin := &limitedReader{r: r, max: CopyMaxBytes}
+	stderr := &stderrBuffer{}
+	cmd := []string{"tar", "xmf", "-", "-C", opts.Path}
+	err := c.withContext(ctx, func() error {
+		return c.execStream(opts.Namespace, opts.PodName, opts.ContainerName, cmd, remotecommand.StreamOptions{
+			Stdin:  stdin,
+			Stderr: stderr,
+		})
+	})
+	n := stdin.count()
+	return n, copyError(err, n > CopyMaxBytes, stderr)
+}
+
+// withContext returns once fn does or ctx is done, the caller unblocks fn
+// by closing its streams. fn may still be running when it returns, so the
+// streams of fn must be safe to read meanwhile.
+func (c *client) withContext(ctx context.Context, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func copyError(err error, tooLarge bool, stderr *stderrBuffer) error {
+	if tooLarge {
+		return fmt.Errorf("archive larger than %d bytes", CopyMaxBytes)
+	}
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%v: %s", err, msg)
+	}
+	return err
+}
+
+// ValidateCopyPath accepts absolute paths without any .. element, other
+// than the root.
+func ValidateCopyPath(p string) error {
+	if !path.IsAbs(p) {
+		return fmt.Errorf("path %q is not absolute", p)
+	}
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return fmt.Errorf("path %q must not contain ..", p)
+		}
+	}
+	if path.Clean(p) == "/" {
+		return fmt.Errorf("path must not be the root")
+	}
+	return nil
+}
+
+// limitedWriter fails once more than max bytes are written.
+type limitedWriter struct {
+	w   io.Writer
+	max int64
+	n   int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt64(&lw.n, int64(len(p))) > lw.max {
+		return 0, errCopyTooLarge
+	}
+	return lw.w.Write(p)
+}
+
+// count returns the bytes written so far.
+func (lw *limitedWriter) count() int64 {
+	return atomic.LoadInt64(&lw.n)
+}
+
+// limitedReader fails once more than max bytes are read.
+type limitedReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	if atomic.AddInt64(&lr.n, int64(n)) > lr.max {
+		return 0, errCopyTooLarge
+	}
+	return n, err
+}
+
+// count returns the bytes read so far.
+func (lr *limitedReader) count() int64 {
+	return atomic.LoadInt64(&lr.n)
+}
+
+// stderrBuffer keeps the start of the tar error output.
+type stderrBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if room := maxStderr - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
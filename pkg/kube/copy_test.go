@@ -0,0 +1,55 @@
+package kube
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCopyPath(t *testing.T) {
+	for _, p := range []string{"/data", "/var/log/app.log", "/tmp/"} {
+		assert.Nil(t, ValidateCopyPath(p), "valid path %s rejected", p)
+	}
+	for _, p := range []string{"", "data", "/", "//", "/data/../etc", "/.."} {
+		assert.NotNil(t, ValidateCopyPath(p), "invalid path %s accepted", p)
+	}
+}
+
+func TestCopyLimits(t *testing.T) {
+	w := &limitedWriter{w: &bytes.Buffer{}, max: 4}
+	_, err := w.Write([]byte("1234"))
+	assert.Nil(t, err, "no error write under the limit")
+	_, err = w.Write([]byte("5"))
+	assert.Equal(t, errCopyTooLarge, err, "write over the limit")
+
+	r := &limitedReader{r: strings.NewReader("12345"), max: 4}
+	_, err = ioutil.ReadAll(r)
+	assert.Equal(t, errCopyTooLarge, err, "read over the limit")
+
+	stderr := &stderrBuffer{}
+	n, err := stderr.Write(make([]byte, maxStderr+10))
+	assert.Equal(t, maxStderr+10, n, "stderr write not fully accepted")
+	assert.Equal(t, maxStderr, len(stderr.String()), "stderr not capped")
+}
+
+func TestCopyCountsWhileStreaming(t *testing.T) {
+	w := &limitedWriter{w: ioutil.Discard, max: 1 << 20}
+	stderr := &stderrBuffer{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.Write([]byte("1234"))
+			stderr.Write([]byte("error"))
+		}
+	}()
+	// the stream may outlive a cancelled copy, so its counts and error
+	// output are read while it still writes
+	assert.True(t, w.count() <= 400, "count ahead of writes")
+	assert.True(t, len(stderr.String()) <= 500, "stderr ahead of writes")
+	<-done
+	assert.Equal(t, int64(400), w.count(), "bad count")
+}
@@ -0,0 +1,110 @@
+package kube
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/glog"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (c *client) GetLogs(ctx context.Context, namespace string, pod string, options *core_v1.PodLogOptions) (io.ReadCloser, error) {
+	return c.client.CoreV1().Pods(namespace).GetLogs(pod, options).Context(ctx).Stream()
+}
+
+// GetSelectorLogs merges the logs of the containers of all pods matching the
+// selector into one stream, each line prefixed with its pod and container.
+// Only the container of the options is followed if it is set.
+func (c *client) GetSelectorLogs(ctx context.Context, namespace string, selector string, options *core_v1.PodLogOptions) (io.ReadCloser, error) {
+	pods, err := c.client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	streams := map[string]io.ReadCloser{}
+	closeAll := func() {
+		cancel()
+		for _, stream := range streams {
+			stream.Close()
+		}
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			if options.Container != "" && container.Name != options.Container {
+				continue
+			}
+			podOptions := options.DeepCopy()
+			podOptions.Container = container.Name
+			stream, err := c.client.CoreV1().Pods(namespace).GetLogs(pod.Name, podOptions).Context(ctx).Stream()
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("get logs of %s/%s: %v", pod.Name, container.Name, err)
+			}
+			streams[fmt.Sprintf("[pod/%s/%s] ", pod.Name, container.Name)] = stream
+		}
+	}
+	if len(streams) == 0 {
+		cancel()
+		return nil, fmt.Errorf("no container matches selector %q in namespace %s", selector, namespace)
+	}
+	return mergeLogs(streams, cancel), nil
+}
+
+type mergedLogs struct {
+	*io.PipeReader
+	streams map[string]io.ReadCloser
+	cancel  context.CancelFunc
+}
+
+// mergeLogs interleaves the streams line by line, keyed by the prefix of
+// their lines. The merged stream ends when all streams end.
+func mergeLogs(streams map[string]io.ReadCloser, cancel context.CancelFunc) io.ReadCloser {
+	r, w := io.Pipe()
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for prefix, stream := range streams {
+		wg.Add(1)
+		go func(prefix string, stream io.Reader) {
+			defer wg.Done()
+			reader := bufio.NewReader(stream)
+			for {
+				line, err := reader.ReadString('\n')
+				if line != "" {
+					if line[len(line)-1] != '\n' {
+						line += "\n"
+					}
+					mtx.Lock()
+					_, werr := io.WriteString(w, prefix+line)
+					mtx.Unlock()
+					if werr != nil {
+						return
+					}
+				}
+				if err != nil {
+					if err != io.EOF {
+						glog.V(1).Infof("logs %s: %v", prefix, err)
+					}
+					return
+				}
+			}
+		}(prefix, stream)
+	}
+	go func() {
+		wg.Wait()
+		w.Close()
+	}()
+	return &mergedLogs{PipeReader: r, streams: streams, cancel: cancel}
+}
+
+func (m *mergedLogs) Close() error {
+	m.cancel()
+	for _, stream := range m.streams {
+		stream.Close()
+	}
+	return m.PipeReader.Close()
+}
@@ -0,0 +1,27 @@
+package kube
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeLogs(t *testing.T) {
+	_, cancel := context.WithCancel(context.Background())
+	merged := mergeLogs(map[string]io.ReadCloser{
+		"[pod/a/app] ": ioutil.NopCloser(strings.NewReader("one\ntwo\n")),
+		"[pod/b/app] ": ioutil.NopCloser(strings.NewReader("three")),
+	}, cancel)
+	defer merged.Close()
+
+	content, err := ioutil.ReadAll(merged)
+	assert.Nil(t, err, "no error read merged logs")
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	sort.Strings(lines)
+	assert.Equal(t, []string{"[pod/a/app] one", "[pod/a/app] two", "[pod/b/app] three"}, lines, "bad merged logs")
+}
@@ -0,0 +1,95 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForward tunnels one TCP connection to a port of a pod over local,
+// until either side closes it or ctx is done.
+func (c *client) PortForward(ctx context.Context, namespace string, podName string, port int, local io.ReadWriter) error {
+	config, err := c.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	pod, err := c.client.CoreV1().Pods(namespace).Get(podName, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase != core_v1.PodRunning {
+		return fmt.Errorf("unable to forward port because pod is not running, current status is %s", pod.Status.Phase)
+	}
+
+	req := c.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward")
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+	streamConn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("dial port forward: %v", err)
+	}
+	defer streamConn.Close()
+
+	headers := http.Header{}
+	headers.Set(core_v1.StreamType, core_v1.StreamTypeError)
+	headers.Set(core_v1.PortHeader, strconv.Itoa(port))
+	headers.Set(core_v1.PortForwardRequestIDHeader, "0")
+	errorStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("create error stream: %v", err)
+	}
+	// nothing is written to the error stream
+	errorStream.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		message, err := ioutil.ReadAll(errorStream)
+		switch {
+		case err != nil:
+			errCh <- fmt.Errorf("read error stream: %v", err)
+		case len(message) > 0:
+			errCh <- fmt.Errorf("forward port %d: %s", port, message)
+		}
+	}()
+
+	headers.Set(core_v1.StreamType, core_v1.StreamTypeData)
+	dataStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("create data stream: %v", err)
+	}
+
+	doneCh := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(local, dataStream)
+		doneCh <- err
+	}()
+	go func() {
+		// tell the pod nothing more is sent once local is done
+		defer dataStream.Close()
+		_, err := io.Copy(dataStream, local)
+		doneCh <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	case err := <-doneCh:
+		return err
+	}
+}
@@ -0,0 +1,180 @@
+package kube
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ResourceReadiness tells whether a workload finished rolling out.
+type ResourceReadiness struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Failed  bool   `json:"failed,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GetReadiness returns the rollout state of the Deployments, StatefulSets,
+// DaemonSets, Jobs and PersistentVolumeClaims of a manifest, other kinds
+// are ready once created. Resources that cannot be read are not ready yet,
+// only terminal states such as a failed Job are failed.
+func (c *client) GetReadiness(namespace string, manifest string) ([]*ResourceReadiness, error) {
+	result, err := c.BuildUnstructured(namespace, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("build unstructured: %v", err)
+	}
+	readiness := make([]*ResourceReadiness, 0, len(result))
+	for _, info := range result {
+		kind := info.Object.GetObjectKind().GroupVersionKind().Kind
+		r := &ResourceReadiness{Kind: kind, Name: info.Name}
+		readiness = append(readiness, r)
+		if err := info.Get(); err != nil {
+			r.Message = err.Error()
+			continue
+		}
+		u, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			r.Ready = true
+			continue
+		}
+		var err error
+		switch kind {
+		case "Deployment":
+			deployment := &appsv1.Deployment{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, deployment); err == nil {
+				r.Ready, r.Failed, r.Message = deploymentReadiness(deployment)
+			}
+		case "StatefulSet":
+			statefulSet := &appsv1.StatefulSet{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, statefulSet); err == nil {
+				r.Ready, r.Message = statefulSetReadiness(statefulSet)
+			}
+		case "DaemonSet":
+			daemonSet := &appsv1.DaemonSet{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, daemonSet); err == nil {
+				r.Ready, r.Message = daemonSetReadiness(daemonSet)
+			}
+		case "Job":
+			job := &batchv1.Job{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, job); err == nil {
+				r.Ready, r.Failed, r.Message = jobReadiness(job)
+			}
+		case "PersistentVolumeClaim":
+			pvc := &core_v1.PersistentVolumeClaim{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pvc); err == nil {
+				r.Ready, r.Failed, r.Message = pvcReadiness(pvc)
+			}
+		default:
+			r.Ready = true
+		}
+		if err != nil {
+			r.Message = err.Error()
+		}
+	}
+	return readiness, nil
+}
+
+func deploymentReadiness(d *appsv1.Deployment) (ready bool, failed bool, message string) {
+	if d.Spec.Paused {
+		return false, false, "rollout is paused"
+	}
+	if d.Status.ObservedGeneration < d.Generation {
+		return false, false, "waiting for the spec to be observed"
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == core_v1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return false, true, c.Message
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas {
+		return false, false, fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, replicas)
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return false, false, fmt.Sprintf("%d old replicas pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return false, false, fmt.Sprintf("%d of %d updated replicas available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	}
+	return true, false, ""
+}
+
+func statefulSetReadiness(s *appsv1.StatefulSet) (bool, string) {
+	if s.Status.ObservedGeneration < s.Generation {
+		return false, "waiting for the spec to be observed"
+	}
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	if s.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d replicas ready", s.Status.ReadyReplicas, replicas)
+	}
+	if s.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return true, ""
+	}
+	if rollingUpdate := s.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		// only the pods from the partition on are updated
+		if updating := replicas - *rollingUpdate.Partition; s.Status.UpdatedReplicas < updating {
+			return false, fmt.Sprintf("%d of %d replicas updated", s.Status.UpdatedReplicas, updating)
+		}
+		return true, ""
+	}
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d of %d replicas updated", s.Status.UpdatedReplicas, replicas)
+	}
+	return true, ""
+}
+
+func daemonSetReadiness(d *appsv1.DaemonSet) (bool, string) {
+	if d.Status.ObservedGeneration < d.Generation {
+		return false, "waiting for the spec to be observed"
+	}
+	if d.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return true, ""
+	}
+	if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d pods updated", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled)
+	}
+	if d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d pods available", d.Status.NumberAvailable, d.Status.DesiredNumberScheduled)
+	}
+	return true, ""
+}
+
+func jobReadiness(j *batchv1.Job) (ready bool, failed bool, message string) {
+	for _, c := range j.Status.Conditions {
+		if c.Status != core_v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			return false, true, c.Message
+		}
+	}
+	completions := int32(1)
+	if j.Spec.Completions != nil {
+		completions = *j.Spec.Completions
+	}
+	return false, false, fmt.Sprintf("%d of %d completions", j.Status.Succeeded, completions)
+}
+
+func pvcReadiness(pvc *core_v1.PersistentVolumeClaim) (ready bool, failed bool, message string) {
+	switch pvc.Status.Phase {
+	case core_v1.ClaimBound:
+		return true, false, ""
+	case core_v1.ClaimLost:
+		return false, true, "claim lost its volume"
+	}
+	return false, false, "waiting for the claim to be bound"
+}
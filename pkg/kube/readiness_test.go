@@ -0,0 +1,107 @@
+package kube
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestDeploymentReadiness(t *testing.T) {
+	replicas := int32(2)
+	d := &appsv1.Deployment{}
+	d.Generation = 2
+	d.Spec.Replicas = &replicas
+	d.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: 2,
+		Replicas:           3,
+		UpdatedReplicas:    2,
+		AvailableReplicas:  2,
+	}
+	ready, failed, _ := deploymentReadiness(d)
+	assert.False(t, ready, "ready with an old replica left")
+	assert.False(t, failed, "failed while rolling out")
+
+	d.Status.Replicas = 2
+	ready, _, _ = deploymentReadiness(d)
+	assert.True(t, ready, "not ready once rolled out")
+
+	d.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: core_v1.ConditionFalse,
+		Reason: "ProgressDeadlineExceeded",
+	}}
+	_, failed, _ = deploymentReadiness(d)
+	assert.True(t, failed, "progress deadline exceeded not failed")
+}
+
+func TestStatefulSetReadiness(t *testing.T) {
+	replicas := int32(3)
+	partition := int32(2)
+	s := &appsv1.StatefulSet{}
+	s.Spec.Replicas = &replicas
+	s.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+	}
+	s.Status = appsv1.StatefulSetStatus{
+		ReadyReplicas:   3,
+		UpdatedReplicas: 1,
+		CurrentRevision: "a",
+		UpdateRevision:  "b",
+	}
+	ready, _ := statefulSetReadiness(s)
+	assert.True(t, ready, "not ready with the partition updated")
+
+	s.Spec.UpdateStrategy.RollingUpdate = nil
+	ready, _ = statefulSetReadiness(s)
+	assert.False(t, ready, "ready with replicas left to update")
+}
+
+func TestJobReadiness(t *testing.T) {
+	j := &batchv1.Job{}
+	ready, failed, _ := jobReadiness(j)
+	assert.False(t, ready || failed, "job done before running")
+
+	j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: core_v1.ConditionTrue}}
+	_, failed, _ = jobReadiness(j)
+	assert.True(t, failed, "failed job not failed")
+
+	j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: core_v1.ConditionTrue}}
+	ready, _, _ = jobReadiness(j)
+	assert.True(t, ready, "complete job not ready")
+}
+
+func TestPVCReadiness(t *testing.T) {
+	pvc := &core_v1.PersistentVolumeClaim{}
+	pvc.Status.Phase = core_v1.ClaimPending
+	ready, failed, _ := pvcReadiness(pvc)
+	assert.False(t, ready || failed, "pending claim done")
+
+	pvc.Status.Phase = core_v1.ClaimBound
+	ready, _, _ = pvcReadiness(pvc)
+	assert.True(t, ready, "bound claim not ready")
+
+	pvc.Status.Phase = core_v1.ClaimLost
+	_, failed, _ = pvcReadiness(pvc)
+	assert.True(t, failed, "lost claim not failed")
+}
+
+func TestGetReadinessReadError(t *testing.T) {
+	server := httptest.NewServer(&resourceServer{exists: true, failures: 1})
+	defer server.Close()
+	c := newResourceTestClient(server)
+
+	readiness, err := c.GetReadiness("env", testConfigMap)
+	assert.Nil(t, err, "no error get readiness")
+	assert.False(t, readiness[0].Ready, "unreadable resource ready")
+	assert.False(t, readiness[0].Failed, "unreadable resource failed")
+	assert.NotEmpty(t, readiness[0].Message, "no read error reported")
+
+	readiness, err = c.GetReadiness("env", testConfigMap)
+	assert.Nil(t, err, "no error get readiness")
+	assert.True(t, readiness[0].Ready, "resource not ready once read")
+}
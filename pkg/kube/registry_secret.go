@@ -0,0 +1,85 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultServiceAccount is the service account pods of an env run as unless
+// their chart says otherwise.
+const DefaultServiceAccount = "default"
+
+// DeleteDockerRegistrySecret deletes a secret of type
+// kubernetes.io/dockerconfigjson, it refuses to delete other secrets such
+// as service account tokens.
+func (c *client) DeleteDockerRegistrySecret(ctx context.Context, namespace string, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	secrets := c.client.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Type != core_v1.SecretTypeDockerConfigJson {
+		return fmt.Errorf("secret %s/%s is of type %s, not a registry secret", namespace, name, secret.Type)
+	}
+	// the secret must not be replaced by another one meanwhile
+	err = secrets.Delete(name, &meta_v1.DeleteOptions{
+		Preconditions: &meta_v1.Preconditions{UID: &secret.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *client) ListDockerRegistrySecrets(namespace string) ([]core_v1.Secret, error) {
+	secrets, err := c.client.CoreV1().Secrets(namespace).List(meta_v1.ListOptions{
+		FieldSelector: "type=" + string(core_v1.SecretTypeDockerConfigJson),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Items, nil
+}
+
+// SetImagePullSecret adds or removes a secret from the imagePullSecrets of
+// a service account.
+func (c *client) SetImagePullSecret(namespace string, serviceAccount string, secretName string, present bool) error {
+	serviceAccounts := c.client.CoreV1().ServiceAccounts(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sa, err := serviceAccounts.Get(serviceAccount, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		secrets := make([]core_v1.LocalObjectReference, 0, len(sa.ImagePullSecrets)+1)
+		found := false
+		for _, secret := range sa.ImagePullSecrets {
+			if secret.Name == secretName {
+				found = true
+				if !present {
+					continue
+				}
+			}
+			secrets = append(secrets, secret)
+		}
+		if found == present {
+			return nil
+		}
+		if present {
+			secrets = append(secrets, core_v1.LocalObjectReference{Name: secretName})
+		}
+		sa.ImagePullSecrets = secrets
+		_, err = serviceAccounts.Update(sa)
+		return err
+	})
+}
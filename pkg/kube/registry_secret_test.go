@@ -0,0 +1,44 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestDeleteDockerRegistrySecret(t *testing.T) {
+	for _, test := range []struct {
+		secretType core_v1.SecretType
+		deleted    bool
+	}{
+		{core_v1.SecretTypeDockerConfigJson, true},
+		{core_v1.SecretTypeServiceAccountToken, false},
+		{core_v1.SecretTypeOpaque, false},
+	} {
+		deleted := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			secret := &core_v1.Secret{Type: test.secretType}
+			secret.Name, secret.Namespace, secret.UID = "registry", "env", "uid"
+			if r.Method == http.MethodDelete {
+				deleted = true
+			}
+			json.NewEncoder(w).Encode(secret)
+		}))
+		clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		assert.Nil(t, err, "no error build client")
+
+		c := &client{client: clientSet}
+		err = c.DeleteDockerRegistrySecret(context.Background(), "env", "registry")
+		assert.Equal(t, test.deleted, err == nil, "bad error deleting a %s secret", test.secretType)
+		assert.Equal(t, test.deleted, deleted, "bad deletion of a %s secret", test.secretType)
+		server.Close()
+	}
+}
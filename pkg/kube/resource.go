@@ -0,0 +1,184 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+var accessor = meta.NewAccessor()
+
+// ApplyResources creates or replaces the objects of a manifest, which must
+// all live in namespace. With dryRun the API server validates and defaults
+// them without persisting anything.
+func (c *client) ApplyResources(ctx context.Context, namespace string, manifest string, dryRun bool) ([]runtime.Object, error) {
+	result, err := c.buildNamespaced(namespace, manifest)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]runtime.Object, 0, len(result))
+	for _, info := range result {
+		if err := ctx.Err(); err != nil {
+			return objects, err
+		}
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		current, err := helper.Get(info.Namespace, info.Name, false)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return objects, fmt.Errorf("get %s %s: %v", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+			}
+			obj, err := helper.Create(info.Namespace, true, info.Object, &meta_v1.CreateOptions{DryRun: dryRunAll(dryRun)})
+			if err != nil {
+				return objects, fmt.Errorf("create %s %s: %v", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+			}
+			objects = append(objects, obj)
+			continue
+		}
+		version, err := accessor.ResourceVersion(current)
+		if err != nil {
+			return objects, err
+		}
+		if err := accessor.SetResourceVersion(info.Object, version); err != nil {
+			return objects, err
+		}
+		// Helper.Replace takes no options, so dry-run needs the request spelled out
+		obj, err := helper.RESTClient.Put().
+			NamespaceIfScoped(info.Namespace, helper.NamespaceScoped).
+			Resource(helper.Resource).
+			Name(info.Name).
+			VersionedParams(&meta_v1.UpdateOptions{DryRun: dryRunAll(dryRun)}, meta_v1.ParameterCodec).
+			Body(info.Object).
+			Do().
+			Get()
+		if err != nil {
+			return objects, fmt.Errorf("replace %s %s: %v", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// DeleteResources deletes the objects of a manifest, which must all live in
+// namespace, objects already gone are skipped.
+func (c *client) DeleteResources(ctx context.Context, namespace string, manifest string, dryRun bool) error {
+	result, err := c.buildNamespaced(namespace, manifest)
+	if err != nil {
+		return err
+	}
+	for _, info := range result {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := resource.NewHelper(info.Client, info.Mapping).DeleteWithOptions(info.Namespace, info.Name, &meta_v1.DeleteOptions{DryRun: dryRunAll(dryRun)})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete %s %s: %v", info.Mapping.GroupVersionKind.Kind, info.Name, err)
+		}
+	}
+	return nil
+}
+
+// buildNamespaced builds a manifest and refuses it unless all of its objects
+// are namespaced and in namespace.
+func (c *client) buildNamespaced(namespace string, manifest string) (Result, error) {
+	result, err := c.BuildUnstructured(namespace, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("build unstructured: %v", err)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no objects in manifest")
+	}
+	for _, info := range result {
+		kind := info.Mapping.GroupVersionKind.Kind
+		if info.Mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return nil, fmt.Errorf("%s %s is not namespaced", kind, info.Name)
+		}
+		if info.Namespace != namespace {
+			return nil, fmt.Errorf("%s %s is in namespace %s instead of %s", kind, info.Name, info.Namespace, namespace)
+		}
+	}
+	return result, nil
+}
+
+func dryRunAll(dryRun bool) []string {
+	if dryRun {
+		return []string{meta_v1.DryRunAll}
+	}
+	return nil
+}
+
+// GetResource returns the live object of a namespaced kind resolved through
+// discovery. Without apiVersion the kind may also be a resource or short
+// name, such as deploy, with apiVersion it must be the kind of that group
+// and version.
+func (c *client) GetResource(namespace string, apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+	mapping, err := c.resourceMapping(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil, fmt.Errorf("%s is not namespaced", mapping.GroupVersionKind.Kind)
+	}
+	config, err := c.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+func (c *client) resourceMapping(apiVersion string, kind string) (*meta.RESTMapping, error) {
+	discoveryClient, err := c.GetDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil {
+		return nil, fmt.Errorf("discover resources: %v", err)
+	}
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDiscoveryRESTMapper(groupResources), discoveryClient)
+	if apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return nil, err
+		}
+		return mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	}
+	gvk, err := mapper.KindFor(schema.GroupVersionResource{Resource: strings.ToLower(kind)})
+	if err != nil {
+		return nil, err
+	}
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// GetResourceEvents returns the events of an object, the latest last.
+func (c *client) GetResourceEvents(obj *unstructured.Unstructured) ([]core_v1.Event, error) {
+	selector := fields.Set{
+		"involvedObject.kind": obj.GetKind(),
+		"involvedObject.name": obj.GetName(),
+		"involvedObject.uid":  string(obj.GetUID()),
+	}.AsSelector().String()
+	events, err := c.client.CoreV1().Events(obj.GetNamespace()).List(meta_v1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[i].LastTimestamp.Before(&events.Items[j].LastTimestamp)
+	})
+	return events.Items, nil
+}
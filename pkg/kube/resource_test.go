@@ -0,0 +1,193 @@
+package kube
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+)
+
+const (
+	testConfigMap  = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"app"}}`
+	otherConfigMap = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"app","namespace":"other"}}`
+	testNamespace  = `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"env"}}`
+)
+
+// resourceServer answers the requests made for the config map app and
+// records them.
+type resourceServer struct {
+	mtx      sync.Mutex
+	exists   bool
+	requests []string
+	// failures is the number of requests failing before the others succeed
+	failures int
+}
+
+func (s *resourceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	request := r.Method + " " + r.URL.Path
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+	s.requests = append(s.requests, request)
+	w.Header().Set("Content-Type", "application/json")
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"etcd unavailable","reason":"InternalError","code":500}`))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if !s.exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"app","namespace":"env","resourceVersion":"1"}}`))
+	case http.MethodDelete:
+		if !s.exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Success"}`))
+	default:
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}
+}
+
+func newResourceTestClient(server *httptest.Server) *client {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	clientConfig := clientcmd.NewDefaultClientConfig(*clientcmdapi.NewConfig(), &clientcmd.ConfigOverrides{
+		ClusterInfo: clientcmdapi.Cluster{Server: server.URL},
+	})
+	flags := genericclioptions.NewTestConfigFlags().
+		WithClientConfig(clientConfig).
+		WithRESTMapper(mapper)
+	return &client{Factory: cmdutil.NewFactory(flags)}
+}
+
+func TestBuildNamespaced(t *testing.T) {
+	server := httptest.NewServer(&resourceServer{})
+	defer server.Close()
+	c := newResourceTestClient(server)
+
+	for _, test := range []struct {
+		name     string
+		manifest string
+		refused  bool
+	}{
+		{"namespaced", testConfigMap, false},
+		{"empty", "", true},
+		{"other namespace", otherConfigMap, true},
+		{"cluster scoped", testNamespace, true},
+		{"one of several refused", testConfigMap + "\n---\n" + testNamespace, true},
+	} {
+		result, err := c.buildNamespaced("env", test.manifest)
+		if test.refused {
+			assert.NotNil(t, err, "%s manifest accepted", test.name)
+			continue
+		}
+		assert.Nil(t, err, "%s manifest refused", test.name)
+		assert.Equal(t, 1, len(result), "%s manifest not built", test.name)
+	}
+}
+
+func TestApplyResources(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		manifest string
+		exists   bool
+		dryRun   bool
+		requests []string
+		refused  bool
+	}{
+		{
+			name:     "create",
+			manifest: testConfigMap,
+			requests: []string{"GET /api/v1/namespaces/env/configmaps/app", "POST /api/v1/namespaces/env/configmaps"},
+		},
+		{
+			name:     "replace",
+			manifest: testConfigMap,
+			exists:   true,
+			requests: []string{"GET /api/v1/namespaces/env/configmaps/app", "PUT /api/v1/namespaces/env/configmaps/app"},
+		},
+		{
+			name:     "dry-run",
+			manifest: testConfigMap,
+			dryRun:   true,
+			requests: []string{"GET /api/v1/namespaces/env/configmaps/app", "POST /api/v1/namespaces/env/configmaps?dryRun=All"},
+		},
+		{name: "other namespace", manifest: otherConfigMap, refused: true},
+		{name: "cluster scoped", manifest: testNamespace, refused: true},
+	} {
+		handler := &resourceServer{exists: test.exists}
+		server := httptest.NewServer(handler)
+		c := newResourceTestClient(server)
+
+		objects, err := c.ApplyResources(context.Background(), "env", test.manifest, test.dryRun)
+		server.Close()
+		if test.refused {
+			assert.NotNil(t, err, "%s applied", test.name)
+			assert.Empty(t, handler.requests, "%s sent requests", test.name)
+			continue
+		}
+		assert.Nil(t, err, "no error %s", test.name)
+		assert.Equal(t, 1, len(objects), "%s returned no object", test.name)
+		assert.Equal(t, test.requests, handler.requests, "bad requests %s", test.name)
+	}
+}
+
+func TestDeleteResources(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		manifest string
+		exists   bool
+		requests []string
+		refused  bool
+	}{
+		{
+			name:     "delete",
+			manifest: testConfigMap,
+			exists:   true,
+			requests: []string{"DELETE /api/v1/namespaces/env/configmaps/app"},
+		},
+		{
+			name:     "already gone",
+			manifest: testConfigMap,
+			requests: []string{"DELETE /api/v1/namespaces/env/configmaps/app"},
+		},
+		{name: "other namespace", manifest: otherConfigMap, refused: true},
+		{name: "cluster scoped", manifest: testNamespace, refused: true},
+	} {
+		handler := &resourceServer{exists: test.exists}
+		server := httptest.NewServer(handler)
+		c := newResourceTestClient(server)
+
+		err := c.DeleteResources(context.Background(), "env", test.manifest, false)
+		server.Close()
+		if test.refused {
+			assert.NotNil(t, err, "%s deleted", test.name)
+			assert.Empty(t, handler.requests, "%s sent requests", test.name)
+			continue
+		}
+		assert.Nil(t, err, "no error %s", test.name)
+		assert.Equal(t, test.requests, handler.requests, "bad requests %s", test.name)
+	}
+}
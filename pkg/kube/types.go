@@ -4,8 +4,21 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/remotecommand"
 )
 
+// ExecOptions describes a command to exec in a container.
+type ExecOptions struct {
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// Command runs instead of the first shell found in the container.
+	Command []string
+	TTY     bool
+	// TerminalSizeQueue resizes the terminal, only used with a TTY.
+	TerminalSizeQueue remotecommand.TerminalSizeQueue
+}
+
 type CommonObject struct {
 	metav1.TypeMeta `json:",inline"`
 	// Standard object's metadata.
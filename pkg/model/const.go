@@ -1,6 +1,12 @@
 package model
 
 const (
+	// websocket
+	Ack            = "ack"
+	Nack           = "nack"
+	CancelCommand  = "cancel_command"
+	UpstreamStatus = "upstream_status"
+
 	//manager
 	InitAgent        = "init_agent"
 	ReSyncAgent      = "re_sync"
@@ -22,6 +28,8 @@ const (
 	HelmReleaseInstallFailed    = "helm_release_install_failed"
 	HelmReleasePreUpgrade       = "helm_release_pre_upgrade"
 	HelmReleaseUpgrade          = "helm_release_upgrade"
+	HelmReleaseReady            = "helm_release_ready"
+	HelmReleaseRolloutFailed    = "helm_release_rollout_failed"
 	HelmReleaseRollback         = "helm_release_rollback"
 	HelmReleaseRollbackFailed   = "helm_release_rollback_failed"
 	HelmReleaseStart            = "helm_release_start"
@@ -76,11 +84,39 @@ const (
 	KubernetesGetLogsFailed           = "kubernetes_get_logs_failed"
 	KubernetesExec                    = "kubernetes_exec"
 	KubernetesExecFailed              = "kubernetes_exec_failed"
+	KubernetesPortForward             = "kubernetes_port_forward"
+	KubernetesPortForwardFailed       = "kubernetes_port_forward_failed"
+	KubernetesCopyFrom                = "kubernetes_copy_from"
+	KubernetesCopyFromFailed          = "kubernetes_copy_from_failed"
+	KubernetesCopyTo                  = "kubernetes_copy_to"
+	KubernetesCopyToFailed            = "kubernetes_copy_to_failed"
+	KubernetesApplyResource           = "kubernetes_apply_resource"
+	KubernetesApplyResourceFailed     = "kubernetes_apply_resource_failed"
+	KubernetesDeleteResource          = "kubernetes_delete_resource"
+	KubernetesDeleteResourceFailed    = "kubernetes_delete_resource_failed"
+	KubernetesGetResource             = "kubernetes_get_resource"
+	KubernetesGetResourceFailed       = "kubernetes_get_resource_failed"
+	PipeClose                         = "pipe_close"
+	PipeCloseFailed                   = "pipe_close_failed"
+	ExecRecordingList                 = "exec_recording_list"
+	ExecRecordingListFailed           = "exec_recording_list_failed"
+	ExecRecordingGet                  = "exec_recording_get"
+	ExecRecordingGetFailed            = "exec_recording_get_failed"
 	OperatePodCount                   = "operate_pod_count"
 	OperatePodCountFailed             = "operate_pod_count_failed"
 	OperatePodCountSuccess            = "operate_pod_count_succeed"
+	RolloutRestart                    = "rollout_restart"
+	RolloutRestartFailed              = "rollout_restart_failed"
+	RolloutPause                      = "rollout_pause"
+	RolloutPauseFailed                = "rollout_pause_failed"
+	RolloutResume                     = "rollout_resume"
+	RolloutResumeFailed               = "rollout_resume_failed"
 	OperateDockerRegistrySecret       = "operate_docker_registry_secret"
 	OperateDockerRegistrySecretFailed = "operate_docker_registry_secret_failed"
+	DeleteDockerRegistrySecret        = "delete_docker_registry_secret"
+	DeleteDockerRegistrySecretFailed  = "delete_docker_registry_secret_failed"
+	ListDockerRegistrySecrets         = "list_docker_registry_secrets"
+	ListDockerRegistrySecretsFailed   = "list_docker_registry_secrets_failed"
 
 	// git ops
 	GitOpsSync       = "git_ops_sync"
@@ -93,4 +129,6 @@ const (
 	UpgradeCluster       = "upgrade_cluster"
 	UpgradeClusterFailed = "upgrade_cluster_failed"
 	CertManagerInfo      = "cert_manager_info"
+	RotateToken          = "rotate_token"
+	RotateTokenFailed    = "rotate_token_failed"
 )
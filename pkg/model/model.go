@@ -9,6 +9,29 @@ type Packet struct {
 	Key     string `json:"key,omitempty"`
 	Type    string `json:"type,omitempty"`
 	Payload string `json:"payload,omitempty"`
+	// Seq identifies a packet on the websocket connection, it is echoed back
+	// in ack/nack packets. Zero means the sender does not expect an ack.
+	// DevOps service keeps the seq of a command when it re-sends it.
+	Seq uint64 `json:"seq,omitempty"`
+	// Fragment is set when the payload was too large for one message and
+	// was split over several packets.
+	Fragment *Fragment `json:"fragment,omitempty"`
+}
+
+// Fragment numbers one part of a split payload. The receiver joins the
+// payloads of the packets with the same Id in Index order once all Total
+// of them arrived.
+type Fragment struct {
+	Id    uint64 `json:"id"`
+	Index int    `json:"index"`
+	Total int    `json:"total"`
+}
+
+// CancelCommandRequest is the payload of a cancel_command packet. It aborts
+// the command with Seq, or every command with Key if Seq is zero.
+type CancelCommandRequest struct {
+	Seq uint64 `json:"seq,omitempty"`
+	Key string `json:"key,omitempty"`
 }
 
 func (c *Packet) String() string {
@@ -25,3 +48,13 @@ func (c *Packet) Namespace() string {
 	}
 	return ""
 }
+
+func (c *Packet) Release() string {
+	keyValues := strings.Split(c.Key, ".")
+	for _, keyValue := range keyValues {
+		if strings.HasPrefix(keyValue, "release:") {
+			return strings.TrimPrefix(keyValue, "release:")
+		}
+	}
+	return ""
+}
@@ -15,3 +15,10 @@ func TestCommandString(t *testing.T) {
 	}
 	assert.Equal(t, "{key: key01, type: type01}: payload01", fmt.Sprint(cmd), "error format")
 }
+
+func TestCommandRelease(t *testing.T) {
+	cmd := &Packet{Key: "env:test.release:test-service.commit:abc"}
+	assert.Equal(t, "test", cmd.Namespace(), "error namespace")
+	assert.Equal(t, "test-service", cmd.Release(), "error release")
+	assert.Equal(t, "", (&Packet{Key: "env:test"}).Release(), "error release")
+}
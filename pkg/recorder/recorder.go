@@ -0,0 +1,214 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const castSuffix = ".cast"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// Recorder keeps exec sessions as asciinema v2 cast files in a directory,
+// one file per pipe.
+type Recorder struct {
+	mtx  sync.Mutex
+	dir  string
+	opts Options
+}
+
+type Options struct {
+	// MaxAge removes recordings older than it, zero keeps them.
+	MaxAge time.Duration
+	// MaxCount removes the oldest recordings beyond it, zero is unlimited.
+	MaxCount int
+	// MaxSize stops recording a session once its file reaches it, zero is
+	// unlimited.
+	MaxSize int64
+}
+
+// Tags tell who did what where, they are kept in the cast header.
+type Tags struct {
+	PipeID        string   `json:"pipeID"`
+	Namespace     string   `json:"namespace"`
+	PodName       string   `json:"podName"`
+	ContainerName string   `json:"containerName"`
+	User          string   `json:"user,omitempty"`
+	Command       []string `json:"command,omitempty"`
+}
+
+// Recording describes a recorded session.
+type Recording struct {
+	Tags
+	Started time.Time `json:"started"`
+	Size    int64     `json:"size"`
+}
+
+type header struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Tags      Tags              `json:"tags"`
+}
+
+func New(dir string, opts Options) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create recording dir %s: %v", dir, err)
+	}
+	return &Recorder{dir: dir, opts: opts}, nil
+}
+
+// Start opens the recording of a session, the size is the one of the
+// terminal, 80x24 if unknown.
+func (r *Recorder) Start(tags Tags, width, height uint16) (*Session, error) {
+	filename, err := r.filename(tags.PipeID)
+	if err != nil {
+		return nil, err
+	}
+	r.prune()
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %v", err)
+	}
+	if width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+	now := time.Now()
+	content, _ := json.Marshal(&header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: now.Unix(),
+		Title:     fmt.Sprintf("%s/%s/%s", tags.Namespace, tags.PodName, tags.ContainerName),
+		Env:       map[string]string{"TERM": "xterm"},
+		Tags:      tags,
+	})
+	content = append(content, '\n')
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, fmt.Errorf("write recording header: %v", err)
+	}
+	glog.Infof("recording exec session %s of %s/%s by %q", tags.PipeID, tags.Namespace, tags.PodName, tags.User)
+	return &Session{
+		id:      tags.PipeID,
+		file:    file,
+		start:   now,
+		size:    int64(len(content)),
+		maxSize: r.opts.MaxSize,
+	}, nil
+}
+
+// List returns the recordings kept, oldest first.
+func (r *Recorder) List() ([]*Recording, error) {
+	r.prune()
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read recording dir %s: %v", r.dir, err)
+	}
+	recordings := make([]*Recording, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), castSuffix) {
+			continue
+		}
+		h, err := readHeader(filepath.Join(r.dir, file.Name()))
+		if err != nil {
+			glog.Warningf("skip recording %s: %v", file.Name(), err)
+			continue
+		}
+		recordings = append(recordings, &Recording{
+			Tags:    h.Tags,
+			Started: time.Unix(h.Timestamp, 0),
+			Size:    file.Size(),
+		})
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].Started.Before(recordings[j].Started)
+	})
+	return recordings, nil
+}
+
+// Get returns the cast file of a pipe.
+func (r *Recorder) Get(pipeID string) ([]byte, error) {
+	filename, err := r.filename(pipeID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recording of pipe %s", pipeID)
+	}
+	return content, err
+}
+
+func (r *Recorder) filename(pipeID string) (string, error) {
+	// the id ends up in a path, keep it from leaving the directory
+	if !validID.MatchString(pipeID) || strings.Contains(pipeID, "..") {
+		return "", fmt.Errorf("invalid pipe id %q", pipeID)
+	}
+	return filepath.Join(r.dir, pipeID+castSuffix), nil
+}
+
+// prune removes the recordings beyond the retention limits.
+func (r *Recorder) prune() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		glog.Errorf("read recording dir %s: %v", r.dir, err)
+		return
+	}
+	casts := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), castSuffix) {
+			casts = append(casts, file)
+		}
+	}
+	sort.Slice(casts, func(i, j int) bool {
+		return casts[i].ModTime().Before(casts[j].ModTime())
+	})
+	now := time.Now()
+	for i, file := range casts {
+		expired := r.opts.MaxAge > 0 && now.Sub(file.ModTime()) > r.opts.MaxAge
+		extra := r.opts.MaxCount > 0 && len(casts)-i > r.opts.MaxCount
+		if !expired && !extra {
+			continue
+		}
+		glog.V(1).Infof("remove recording %s", file.Name())
+		if err := os.Remove(filepath.Join(r.dir, file.Name())); err != nil {
+			glog.Errorf("remove recording %s: %v", file.Name(), err)
+		}
+	}
+}
+
+func readHeader(filename string) (*header, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	h := &header{}
+	if err := json.Unmarshal(line, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
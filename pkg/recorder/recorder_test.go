@@ -0,0 +1,64 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+type sizes []remotecommand.TerminalSize
+
+func (s *sizes) Next() *remotecommand.TerminalSize {
+	if len(*s) == 0 {
+		return nil
+	}
+	size := (*s)[0]
+	*s = (*s)[1:]
+	return &size
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	assert.Nil(t, err, "no error create temp dir")
+	defer os.RemoveAll(dir)
+
+	r, err := New(dir, Options{MaxCount: 1})
+	assert.Nil(t, err, "no error create recorder")
+	_, err = r.Start(Tags{PipeID: "../escape"}, 0, 0)
+	assert.NotNil(t, err, "bad pipe id accepted")
+
+	tags := Tags{PipeID: "pipe01", Namespace: "env", PodName: "pod", ContainerName: "app", User: "admin"}
+	session, err := r.Start(tags, 120, 40)
+	assert.Nil(t, err, "no error start recording")
+	stream := session.ReadWriter(&bytes.Buffer{})
+	stream.Write([]byte("ls\r\n"))
+	stream.Read(make([]byte, 16))
+	session.SizeQueue(&sizes{{Width: 100, Height: 30}}).Next()
+	session.Close()
+
+	content, err := r.Get("pipe01")
+	assert.Nil(t, err, "no error get recording")
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, 4, len(lines), "bad event count")
+	var h header
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &h), "no error parse header")
+	assert.Equal(t, 2, h.Version, "not an asciinema v2 header")
+	assert.Equal(t, uint16(120), h.Width, "bad terminal width")
+	assert.Equal(t, "admin", h.Tags.User, "user not tagged")
+	assert.Contains(t, lines[1], `"o","ls\r\n"`, "output not recorded")
+	assert.Contains(t, lines[2], `"i","ls\r\n"`, "input not recorded")
+	assert.Contains(t, lines[3], `"r","100x30"`, "resize not recorded")
+
+	_, err = r.Start(Tags{PipeID: "pipe02"}, 0, 0)
+	assert.Nil(t, err, "no error start recording")
+	recordings, err := r.List()
+	assert.Nil(t, err, "no error list recordings")
+	assert.Equal(t, 1, len(recordings), "oldest recording not removed")
+	assert.Equal(t, "pipe02", recordings[0].PipeID, "bad recording kept")
+}
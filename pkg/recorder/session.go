@@ -0,0 +1,103 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Session writes the events of one exec session to its cast file.
+type Session struct {
+	mtx     sync.Mutex
+	id      string
+	file    *os.File
+	start   time.Time
+	size    int64
+	maxSize int64
+	full    bool
+}
+
+// ReadWriter records what is read from rw as input and what is written to
+// it as output, rw being the stdin and stdout of the exec stream.
+func (s *Session) ReadWriter(rw io.ReadWriter) io.ReadWriter {
+	return &recordedReadWriter{ReadWriter: rw, session: s}
+}
+
+// SizeQueue records the sizes the terminal is resized to.
+func (s *Session) SizeQueue(q remotecommand.TerminalSizeQueue) remotecommand.TerminalSizeQueue {
+	return &recordedSizeQueue{queue: q, session: s}
+}
+
+func (s *Session) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	glog.Infof("exec session %s recorded, %d bytes", s.id, s.size)
+	return err
+}
+
+func (s *Session) event(code string, data string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.file == nil || s.full {
+		return
+	}
+	elapsed := float64(time.Since(s.start)/time.Microsecond) / 1e6
+	line, _ := json.Marshal([]interface{}{elapsed, code, data})
+	line = append(line, '\n')
+	if s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize {
+		glog.Warningf("recording of exec session %s reached %d bytes, stop recording", s.id, s.maxSize)
+		s.full = true
+		return
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		glog.Errorf("write recording of exec session %s: %v", s.id, err)
+		s.full = true
+	}
+}
+
+type recordedReadWriter struct {
+	io.ReadWriter
+	session *Session
+}
+
+func (rw *recordedReadWriter) Read(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Read(p)
+	if n > 0 {
+		rw.session.event("i", string(p[:n]))
+	}
+	return n, err
+}
+
+func (rw *recordedReadWriter) Write(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Write(p)
+	if n > 0 {
+		rw.session.event("o", string(p[:n]))
+	}
+	return n, err
+}
+
+type recordedSizeQueue struct {
+	queue   remotecommand.TerminalSizeQueue
+	session *Session
+}
+
+func (q *recordedSizeQueue) Next() *remotecommand.TerminalSize {
+	size := q.queue.Next()
+	if size != nil {
+		q.session.event("r", fmt.Sprintf("%dx%d", size.Width, size.Height))
+	}
+	return size
+}
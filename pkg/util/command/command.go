@@ -1,6 +1,8 @@
 package command
 
 import (
+	"context"
+
 	"github.com/choerodon/choerodon-cluster-agent/controller"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/namespace"
@@ -9,6 +11,7 @@ import (
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kube"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/kubernetes"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/recorder"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/websocket"
 	"sync"
 	"time"
@@ -30,4 +33,15 @@ type Opts struct {
 	PlatformCode      string
 	WsClient          websocket.Client
 	Token             string
+	// Recorder records exec sessions, nil if recording is off.
+	Recorder *recorder.Recorder
+	// ReleaseRolloutTimeout bounds the wait for an installed or upgraded
+	// release to be ready, 0 does not wait.
+	ReleaseRolloutTimeout time.Duration
+	// FollowUp runs fn once the response of the command is handed over, fn
+	// answers through respond and returns when ctx is done. Draining the
+	// agent waits for follow-ups as for commands.
+	FollowUp func(fn FollowUpFunc)
 }
+
+type FollowUpFunc func(ctx context.Context, respond func(*model.Packet))
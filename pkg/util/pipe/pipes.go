@@ -18,8 +18,10 @@ type Pipe interface {
 }
 
 const (
-	Log  = "log"
-	Exec = "exec"
+	Log         = "log"
+	Exec        = "exec"
+	PortForward = "portforward"
+	Copy        = "copy"
 )
 
 type pipe struct {
@@ -44,8 +46,26 @@ func NewPipeFromEnds(local, remote io.ReadWriter, pipeType string) Pipe {
 }
 
 func NewPipe(pipeType string) Pipe {
+	return newPipe(pipeType, nil)
+}
+
+// NewTerminalPipe returns a pipe whose resize frames are pushed to sizes
+// instead of being written to the local end, sizes is closed with the pipe.
+func NewTerminalPipe(pipeType string, sizes *TerminalSizeQueue) Pipe {
+	return newPipe(pipeType, sizes)
+}
+
+func newPipe(pipeType string, sizes *TerminalSizeQueue) Pipe {
 	r1, w1 := io.Pipe()
 	r2, w2 := io.Pipe()
+	closers := []io.Closer{
+		r1, r2, w1, w2,
+	}
+	var remoteWriter io.Writer = w1
+	if sizes != nil {
+		remoteWriter = &resizeWriter{Writer: w1, sizes: sizes}
+		closers = append(closers, sizes)
+	}
 	local := struct {
 		io.Reader
 		io.Writer
@@ -56,14 +76,12 @@ func NewPipe(pipeType string) Pipe {
 		io.Reader
 		io.Writer
 	}{
-		r2, w1,
+		r2, remoteWriter,
 	}
 	return &pipe{
-		local:  local,
-		remote: remote,
-		closers: []io.Closer{
-			r1, r2, w1, w2,
-		},
+		local:    local,
+		remote:   remote,
+		closers:  closers,
 		quit:     make(chan struct{}),
 		pipeType: pipeType,
 	}
@@ -95,10 +113,17 @@ func (p *pipe) Closed() bool {
 	return p.closed
 }
 
+// OnClose sets the func called once the pipe is closed, it is called right
+// away if the pipe is already closed.
 func (p *pipe) OnClose(f func()) {
 	p.mtx.Lock()
-	defer p.mtx.Unlock()
-	p.onClose = f
+	if !p.closed {
+		p.onClose = f
+		p.mtx.Unlock()
+		return
+	}
+	p.mtx.Unlock()
+	f()
 }
 
 func (p *pipe) Ends() (io.ReadWriter, io.ReadWriter) {
@@ -0,0 +1,93 @@
+package pipe
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/golang/glog"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ResizeFrame is the first byte of an exec pipe message that resizes the
+// terminal instead of being written to stdin, the rest of the message is a
+// JSON TerminalSize such as {"width":120,"height":40}.
+const ResizeFrame = 0xFF
+
+type TerminalSize struct {
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
+
+// TerminalSizeQueue hands the sizes sent by DevOps service to the exec
+// stream, only the latest size is kept if the stream is slow to take it.
+type TerminalSizeQueue struct {
+	sizes chan remotecommand.TerminalSize
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewTerminalSizeQueue(initial TerminalSize) *TerminalSizeQueue {
+	q := &TerminalSizeQueue{
+		sizes: make(chan remotecommand.TerminalSize, 1),
+		done:  make(chan struct{}),
+	}
+	if initial.Width > 0 && initial.Height > 0 {
+		q.Push(initial)
+	}
+	return q
+}
+
+func (q *TerminalSizeQueue) Push(size TerminalSize) {
+	next := remotecommand.TerminalSize{Width: size.Width, Height: size.Height}
+	for {
+		select {
+		case q.sizes <- next:
+			return
+		default:
+		}
+		// drop the size not taken yet, it is outdated
+		select {
+		case <-q.sizes:
+		default:
+		}
+	}
+}
+
+// Next implements remotecommand.TerminalSizeQueue, it returns nil once the
+// queue is closed.
+func (q *TerminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+func (q *TerminalSizeQueue) Close() error {
+	q.once.Do(func() {
+		close(q.done)
+	})
+	return nil
+}
+
+// resizeWriter writes the messages of an exec pipe to stdin, except resize
+// frames which go to the size queue. Every write is one websocket message.
+type resizeWriter struct {
+	io.Writer
+	sizes *TerminalSizeQueue
+}
+
+func (w *resizeWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 || p[0] != ResizeFrame {
+		return w.Writer.Write(p)
+	}
+	var size TerminalSize
+	if err := json.Unmarshal(p[1:], &size); err != nil || size.Width == 0 || size.Height == 0 {
+		glog.Warningf("ignore bad resize frame %q: %v", p[1:], err)
+		return len(p), nil
+	}
+	w.sizes.Push(size)
+	return len(p), nil
+}
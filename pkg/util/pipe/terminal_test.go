@@ -0,0 +1,31 @@
+package pipe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminalPipeResize(t *testing.T) {
+	sizes := NewTerminalSizeQueue(TerminalSize{Width: 80, Height: 24})
+	p := NewTerminalPipe(Exec, sizes)
+	local, remote := p.Ends()
+
+	size := sizes.Next()
+	assert.Equal(t, uint16(80), size.Width, "initial size not queued")
+
+	remote.Write(append([]byte{ResizeFrame}, `{"width":120,"height":40}`...))
+	remote.Write(append([]byte{ResizeFrame}, `{"width":160,"height":50}`...))
+	size = sizes.Next()
+	assert.Equal(t, uint16(160), size.Width, "latest size not kept")
+	assert.Equal(t, uint16(50), size.Height, "latest size not kept")
+
+	go remote.Write([]byte("ls\n"))
+	buf := make([]byte, 16)
+	n, err := local.Read(buf)
+	assert.Nil(t, err, "no error read stdin")
+	assert.Equal(t, "ls\n", string(buf[:n]), "stdin not passed through")
+
+	p.Close()
+	assert.Nil(t, sizes.Next(), "size queue not closed with the pipe")
+}
@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backOff computes exponentially growing delays between retries. Half of
+// every delay is random, so that agents cut off at the same time do not
+// reconnect to DevOps service all at once.
+type backOff struct {
+	initial time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newBackOff(initial, max time.Duration) *backOff {
+	if initial <= 0 {
+		initial = initialBackOff
+	}
+	if max < initial {
+		max = initial
+	}
+	return &backOff{
+		initial: initial,
+		max:     max,
+		current: initial,
+	}
+}
+
+// Next returns the delay before the next retry and doubles the following one.
+func (b *backOff) Next() time.Duration {
+	d := b.current
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Reset starts again from the initial delay, after a retry succeeded.
+func (b *backOff) Reset() {
+	b.current = b.initial
+}
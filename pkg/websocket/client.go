@@ -1,10 +1,13 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/outbox"
 	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
+	"io"
 	"net/http"
 	"net/url"
 	"sync"
@@ -13,6 +16,8 @@ import (
 	"github.com/golang/glog"
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-cleanhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 	util_url "github.com/choerodon/choerodon-cluster-agent/pkg/util/url"
@@ -23,52 +28,189 @@ const (
 	WriteWait      = 10 * time.Second
 	initialBackOff = 1 * time.Second
 	maxBackOff     = 60 * time.Second
+	// DefaultFailoverAfter is the number of dial errors in a row after which
+	// the next upstream endpoint is tried.
+	DefaultFailoverAfter = 3
 )
 
-var reconnectFlag = false
+var (
+	connected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "choerodon_agent_websocket_connected",
+		Help: "Whether the agent is connected to DevOps service.",
+	})
+	reconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "choerodon_agent_websocket_reconnects_total",
+		Help: "Number of times the agent reconnected to DevOps service.",
+	})
+	lastPong = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "choerodon_agent_websocket_last_pong_timestamp_seconds",
+		Help: "Time the last pong was received from DevOps service.",
+	})
+	failovers = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "choerodon_agent_websocket_failovers_total",
+		Help: "Number of times the agent moved on to the next upstream endpoint.",
+	})
+	pipesOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "choerodon_agent_pipes_open",
+		Help: "Number of exec and log pipes open.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(connected, reconnects, lastPong, failovers, pipesOpen)
+}
 
 type Client interface {
 	Loop(stopCh <-chan struct{}, done *sync.WaitGroup)
 	PipeConnection(pipeID string, pipe pipeutil.Pipe) error
 	PipeClose(pipeID string, pipe pipeutil.Pipe) error
+	// ClosePipe closes an open pipe and the kube stream behind it, it returns
+	// false if no pipe with that id is open.
+	ClosePipe(pipeID string) bool
+	Token() Token
+	// UpdateToken reconnects to DevOps service with a new token, pipe
+	// connections are kept.
+	UpdateToken(t Token)
+	// Flush delivers the responses still buffered, the ones left when ctx
+	// is done are kept in the outbox.
+	Flush(ctx context.Context)
 }
 
 type appClient struct {
-	url            *url.URL
+	urls           []*url.URL
+	current        int
+	dialFailures   int
+	failoverAfter  int
 	token          Token
 	crChannel      *channel.CRChan
 	conn           *websocket.Conn
+	writeMtx       sync.Mutex
 	quit           chan struct{}
 	mtx            sync.Mutex
 	client         *http.Client
 	backgroundWait sync.WaitGroup
 	pipeConns      map[string]*websocket.Conn
-	respQueue      []*model.Packet
+	pipes          map[string]*pipeEntry
+	maxPipes       int
+	pipeIdle       time.Duration
+	outbox         outbox.Outbox
+	ackResponses   bool
+	dialer         *websocket.Dialer
+	pipeDialer     *websocket.Dialer
+	maxPayloadSize int
+	fragmentID     uint64
+	pingInterval   time.Duration
+	pongWait       time.Duration
+	backOff        *backOff
+	// reconnectFlag is only touched by Loop and the connect it waits for.
+	reconnectFlag bool
+}
+
+// Options tunes how the client talks to DevOps service.
+type Options struct {
+	// Outbox keeps responses until they are delivered, an in-memory outbox
+	// is used if it is nil.
+	Outbox outbox.Outbox
+	// AckResponses keeps every response in the outbox until DevOps service
+	// acknowledges it, instead of only the ones that failed to be written.
+	AckResponses bool
+	// Compression negotiates permessage-deflate with DevOps service, messages
+	// are sent uncompressed if it does not support it.
+	Compression bool
+	// MaxPayloadSize splits larger payloads into numbered fragments, zero
+	// sends every payload in one message.
+	MaxPayloadSize int
+	// PingInterval is how often the connection is pinged, zero disables
+	// pings. A connection is dropped when nothing, pongs included, is read
+	// from it within PongWait, by default twice PingInterval.
+	PingInterval time.Duration
+	PongWait     time.Duration
+	// ReconnectBackOff and MaxReconnectBackOff bound the jittered delay
+	// between reconnects, it doubles after every failed dial.
+	ReconnectBackOff    time.Duration
+	MaxReconnectBackOff time.Duration
+	// TLS applies to the main connection and to pipe connections.
+	TLS *TLSOptions
+	// FailoverAfter is the number of dial errors in a row after which the
+	// next endpoint is tried.
+	FailoverAfter int
+	// MaxPipes caps the exec and log pipes open at once, 0 is unlimited.
+	MaxPipes int
+	// PipeIdleTimeout closes pipes nothing went through for that long,
+	// 0 keeps them open until either side closes them.
+	PipeIdleTimeout time.Duration
 }
 
+// NewClient connects to the first of the endpoints and moves on to the next
+// one, in order, when dialing the current one keeps failing.
 func NewClient(
 	t Token,
-	endpoint string,
-	crChannel *channel.CRChan) (Client, error) {
-	if endpoint == "" {
+	endpoints []string,
+	crChannel *channel.CRChan,
+	opts *Options) (Client, error) {
+	if len(endpoints) == 0 {
 		return nil, fmt.Errorf("no upstream URL given")
 	}
 
-	endpointURL, err := url.Parse(endpoint)
+	endpointURLs := make([]*url.URL, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint == "" {
+			return nil, fmt.Errorf("empty upstream URL given")
+		}
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("parsing endpoint %s: %v", endpoint, err)
+		}
+		endpointURLs = append(endpointURLs, endpointURL)
+	}
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	respOutbox := opts.Outbox
+	if respOutbox == nil {
+		respOutbox = outbox.NewMemory(outbox.Options{})
+	}
+
+	pongWait := opts.PongWait
+	if opts.PingInterval > 0 && pongWait <= 0 {
+		pongWait = 2 * opts.PingInterval
+	}
+	failoverAfter := opts.FailoverAfter
+	if failoverAfter <= 0 {
+		failoverAfter = DefaultFailoverAfter
+	}
+	maxReconnectBackOff := opts.MaxReconnectBackOff
+	if maxReconnectBackOff <= 0 {
+		maxReconnectBackOff = maxBackOff
+	}
+
+	tlsConfig, err := opts.TLS.Config()
 	if err != nil {
-		return nil, fmt.Errorf("parsing endpoint %s: %v", endpoint, err)
+		return nil, err
 	}
 
 	httpClient := cleanhttp.DefaultClient()
 
 	c := &appClient{
-		url:       endpointURL,
-		token:     t,
-		crChannel: crChannel,
-		quit:      make(chan struct{}),
-		client:    httpClient,
-		pipeConns: make(map[string]*websocket.Conn),
-		respQueue: make([]*model.Packet, 0, 100),
+		urls:           endpointURLs,
+		failoverAfter:  failoverAfter,
+		token:          t,
+		crChannel:      crChannel,
+		quit:           make(chan struct{}),
+		client:         httpClient,
+		pipeConns:      make(map[string]*websocket.Conn),
+		pipes:          make(map[string]*pipeEntry),
+		maxPipes:       opts.MaxPipes,
+		pipeIdle:       opts.PipeIdleTimeout,
+		outbox:         respOutbox,
+		ackResponses:   opts.AckResponses,
+		dialer:         newDialer(opts.Compression, tlsConfig),
+		pipeDialer:     newPipeDialer(tlsConfig),
+		maxPayloadSize: opts.MaxPayloadSize,
+		pingInterval:   opts.PingInterval,
+		pongWait:       pongWait,
+		backOff:        newBackOff(opts.ReconnectBackOff, maxReconnectBackOff),
 	}
 
 	return c, nil
@@ -78,8 +220,10 @@ func (c *appClient) Loop(stop <-chan struct{}, done *sync.WaitGroup) {
 	defer done.Done()
 
 	glog.Info("Started websocket listening")
+	if c.pipeIdle > 0 {
+		go c.closeIdlePipes(stop)
+	}
 
-	backOff := 5 * time.Second
 	errCh := make(chan error, 1)
 	for {
 		go func() {
@@ -89,9 +233,21 @@ func (c *appClient) Loop(stop <-chan struct{}, done *sync.WaitGroup) {
 		case err := <-errCh:
 			if err != nil {
 				glog.Error(err)
-				reconnectFlag = true
+				c.reconnectFlag = true
+			} else {
+				// the connection was up, start over from the shortest delay
+				c.backOff.Reset()
 			}
-			time.Sleep(backOff)
+		case <-stop:
+			glog.Info("Shutting down agent")
+			c.stop()
+			return
+		}
+		delay := c.backOff.Next()
+		glog.Infof("Reconnect to DevOps service in %s", delay)
+		select {
+		case <-time.After(delay):
+			reconnects.Inc()
 		case <-stop:
 			glog.Info("Shutting down agent")
 			c.stop()
@@ -102,22 +258,36 @@ func (c *appClient) Loop(stop <-chan struct{}, done *sync.WaitGroup) {
 
 func (c *appClient) connect() error {
 	glog.V(1).Info("Start connect to DevOps service")
-	var err error
-	c.conn, err = dial(c.url.String(), c.token)
+	upstream := c.url()
+	conn, err := dial(c.dialer, upstream.String(), c.Token())
 	if err != nil {
+		c.dialFailed()
 		return err
 	}
+	c.writeMtx.Lock()
+	c.conn = conn
+	c.writeMtx.Unlock()
 	glog.V(1).Info("Connect to DevOps service success")
+	connected.Set(1)
+	c.mtx.Lock()
+	c.dialFailures = 0
+	current := c.current
+	c.mtx.Unlock()
+
+	if err := c.write(newUpstreamStatus(upstream, current, len(c.urls))); err != nil {
+		glog.Errorf("send upstream status: %v", err)
+	}
 
 	// 建立连接，同步资源对象
-	if reconnectFlag {
+	if c.reconnectFlag {
 		c.crChannel.CommandChan <- newReConnectCommand()
 	} else {
-		c.crChannel.CommandChan <- newUpgradeInfoCommand(c.url.String())
+		c.crChannel.CommandChan <- newUpgradeInfoCommand(upstream.String())
 	}
 
 	defer func() {
 		glog.V(1).Info("stop websocket connect")
+		connected.Set(0)
 		c.conn.Close()
 	}()
 
@@ -126,6 +296,12 @@ func (c *appClient) connect() error {
 		defer close(done)
 
 		c.conn.SetPingHandler(nil)
+		c.conn.SetPongHandler(func(string) error {
+			lastPong.SetToCurrentTime()
+			c.extendReadDeadline()
+			return nil
+		})
+		c.extendReadDeadline()
 		for {
 			var command model.Packet
 			err := c.conn.ReadJSON(&command)
@@ -135,49 +311,243 @@ func (c *appClient) connect() error {
 				}
 				break
 			}
-			glog.V(1).Info("receive command: ", command)
+			c.extendReadDeadline()
+			switch command.Type {
+			case model.Ack:
+				c.ackResponse(command.Seq)
+				continue
+			case model.Nack:
+				c.resendResponse(command.Seq)
+				continue
+			}
+			// payloads may carry credentials, such as the token of rotate_token
+			glog.V(1).Infof("receive command: %s/%s", command.Key, command.Type)
+			if command.Seq > 0 {
+				if err := c.write(newAck(&command)); err != nil {
+					glog.Errorf("ack command %s/%s seq %d: %v", command.Key, command.Type, command.Seq, err)
+				}
+			}
 			c.crChannel.CommandChan <- &command
 		}
 	}()
 
-	end := 0
-	for ; end < len(c.respQueue); end++ {
-		c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
-		resp := c.respQueue[end]
-		if err := c.sendResponse(resp); err != nil {
-			c.respQueue = c.respQueue[end:]
-			return err
-		}
+	if c.pingInterval > 0 {
+		go c.ping(done)
+	}
+
+	if err := c.replayOutbox(); err != nil {
+		return err
 	}
-	c.respQueue = c.respQueue[end:]
 
 	for {
 		select {
 		case <-done:
 			return nil
 		case resp, ok := <-c.crChannel.ResponseChan:
-			c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
 			if !ok {
+				c.writeMtx.Lock()
+				c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.writeMtx.Unlock()
 				return nil
 			}
-			if err := c.sendResponse(resp); err != nil {
-				glog.Error(err)
-				c.respQueue = append(c.respQueue, resp)
+			c.deliver(resp)
+		}
+	}
+}
+
+// url returns the endpoint currently connected to, or dialed next.
+func (c *appClient) url() *url.URL {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.urls[c.current]
+}
+
+// dialFailed moves on to the next endpoint once dialing the current one
+// failed failoverAfter times in a row.
+func (c *appClient) dialFailed() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.dialFailures++
+	if len(c.urls) < 2 || c.dialFailures < c.failoverAfter {
+		return
+	}
+	from := c.urls[c.current]
+	c.current = (c.current + 1) % len(c.urls)
+	c.dialFailures = 0
+	failovers.Inc()
+	glog.Warningf("Fail over from upstream %s to %s", from, c.urls[c.current])
+}
+
+func (c *appClient) Token() Token {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.token
+}
+
+func (c *appClient) UpdateToken(t Token) {
+	c.mtx.Lock()
+	if t == c.token {
+		c.mtx.Unlock()
+		return
+	}
+	c.token = t
+	c.mtx.Unlock()
+
+	glog.Info("Token updated, reconnect to DevOps service")
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	if c.conn != nil {
+		// the reading goroutine fails and Loop dials again with the new token
+		c.conn.Close()
+	}
+}
+
+func (c *appClient) Flush(ctx context.Context) {
+	flushed, kept := 0, 0
+	for {
+		select {
+		case resp := <-c.crChannel.ResponseChan:
+			if ctx.Err() != nil {
+				c.keepResponse(resp)
+				kept++
+				continue
+			}
+			// written if connected, kept in the outbox otherwise
+			c.deliver(resp)
+			flushed++
+		default:
+			glog.Infof("flushed %d responses, %d kept in outbox", flushed, kept)
+			return
+		}
+	}
+}
+
+// extendReadDeadline gives the peer another pong wait to show it is alive.
+func (c *appClient) extendReadDeadline() {
+	if c.pongWait > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	}
+}
+
+// ping keeps pinging the connection until it is done, a peer that stopped
+// answering is detected by the read deadline.
+func (c *appClient) ping(done <-chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WriteWait)); err != nil {
+				glog.Errorf("ping DevOps service: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// deliver sends a response, keeping it in the outbox when it can not be
+// written or, if acks are enabled, until DevOps service acknowledges it.
+func (c *appClient) deliver(resp *model.Packet) {
+	if !c.ackResponses {
+		if err := c.sendResponse(resp); err != nil {
+			glog.Error(err)
+			c.keepResponse(resp)
+		}
+		return
+	}
+	seq := c.keepResponse(resp)
+	if err := c.sendResponse(withSeq(resp, seq)); err != nil {
+		glog.Error(err)
+	}
+}
+
+// replayOutbox resends the responses kept during the last outage in the
+// order they were produced.
+func (c *appClient) replayOutbox() error {
+	records, err := c.outbox.List()
+	if err != nil {
+		return fmt.Errorf("list outbox: %v", err)
+	}
+	if len(records) > 0 {
+		glog.Infof("replay %d responses from outbox", len(records))
+	}
+	for _, record := range records {
+		if c.ackResponses {
+			if err := c.sendResponse(withSeq(record.Packet, record.Seq)); err != nil {
+				return err
 			}
+			continue
+		}
+		if err := c.sendResponse(record.Packet); err != nil {
+			return err
 		}
+		if err := c.outbox.Remove(record.Seq); err != nil {
+			glog.Errorf("remove outbox record %d: %v", record.Seq, err)
+		}
+	}
+	return nil
+}
+
+func (c *appClient) keepResponse(resp *model.Packet) uint64 {
+	seq, err := c.outbox.Put(resp)
+	if err != nil {
+		glog.Errorf("keep response key %s, type %s: %v", resp.Key, resp.Type, err)
+	}
+	return seq
+}
+
+func (c *appClient) ackResponse(seq uint64) {
+	glog.V(1).Infof("response seq %d acked", seq)
+	if err := c.outbox.Remove(seq); err != nil {
+		glog.Errorf("remove outbox record %d: %v", seq, err)
+	}
+}
+
+func (c *appClient) resendResponse(seq uint64) {
+	record, ok := c.outbox.Get(seq)
+	if !ok {
+		glog.Warningf("response seq %d nacked but no longer kept", seq)
+		return
+	}
+	glog.Infof("response seq %d nacked, resend it", seq)
+	if err := c.sendResponse(withSeq(record.Packet, record.Seq)); err != nil {
+		glog.Error(err)
 	}
 }
 
 func (c *appClient) sendResponse(resp *model.Packet) error {
-	content, _ := json.Marshal(resp)
 	glog.Infof("send response key %s, type %s", resp.Key, resp.Type)
-	glog.V(1).Info("send response: ", string(content))
-	//if len(content) > 65535 {
-	//	glog.Errorf("message %s/%s to large", resp.Key, resp.Type)
-	//	return nil
-	//}
-	return c.conn.WriteMessage(websocket.TextMessage, content)
+	return c.write(resp)
+}
+
+// write serializes writes to the connection, acks are written from the
+// reading goroutine. The fragments of a large packet are written one after
+// another without other packets in between.
+func (c *appClient) write(packet *model.Packet) error {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("not connected to DevOps service")
+	}
+
+	packets := []*model.Packet{packet}
+	if c.maxPayloadSize > 0 && len(packet.Payload) > c.maxPayloadSize {
+		c.fragmentID++
+		packets = fragment(packet, c.maxPayloadSize, c.fragmentID)
+		glog.V(1).Infof("split packet %s/%s into %d fragments", packet.Key, packet.Type, len(packets))
+	}
+	for _, p := range packets {
+		content, _ := json.Marshal(p)
+		glog.V(1).Info("send packet: ", string(content))
+		c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, content); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *appClient) hasQuit() bool {
@@ -206,53 +576,76 @@ func (c *appClient) releaseGoroutine() {
 func (c *appClient) stop() {
 	c.mtx.Lock()
 	close(c.quit)
+	pipes := make([]pipeutil.Pipe, 0, len(c.pipes))
+	for _, entry := range c.pipes {
+		pipes = append(pipes, entry.pipe)
+	}
 	c.mtx.Unlock()
 
+	// pipe goroutines only exit once their pipe is closed
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
 	c.backgroundWait.Wait()
 }
 
 func (c *appClient) PipeConnection(id string, pipe pipeutil.Pipe) error {
+	entry, err := c.registerPipe(id, pipe)
+	if err != nil {
+		return err
+	}
 	go func() {
-		glog.Infof("Pipe %s connection to %s starting", id, c.url)
-		defer glog.Infof("Pipe %s connection to %s exiting", id, c.url)
+		glog.Infof("Pipe %s connection to %s starting", id, c.url())
+		defer glog.Infof("Pipe %s connection to %s exiting", id, c.url())
 		c.doWithBackOff(id, func() (bool, error) {
-			return c.pipeConnection(id, pipe)
+			return c.pipeConnection(id, entry)
 		})
 	}()
 	return nil
 }
 
+// PipeClose is called once a pipe is closed, whichever side closed it.
 func (c *appClient) PipeClose(id string, pipe pipeutil.Pipe) error {
-	//todo imp close func
+	c.unregisterPipe(id, pipe)
+	c.closePipeConn(id)
 	return nil
 }
 
+func (c *appClient) ClosePipe(id string) bool {
+	c.mtx.Lock()
+	entry, ok := c.pipes[id]
+	c.mtx.Unlock()
+	if !ok {
+		return false
+	}
+	glog.Infof("Pipe %s closed by DevOps service", id)
+	entry.pipe.Close()
+	return true
+}
+
 func (c *appClient) doWithBackOff(msg string, f func() (bool, error)) {
 	if !c.retainGoroutine() {
 		return
 	}
 	defer c.releaseGoroutine()
 
-	backOff := initialBackOff
+	backOff := newBackOff(initialBackOff, maxBackOff)
 	for {
 		done, err := f()
 		if done {
 			return
 		}
 		if err == nil {
-			backOff = initialBackOff
+			backOff.Reset()
 			continue
 		}
-		glog.Errorf("Error doing %s for %s, backing off %s: %v", msg, c.url, backOff, err)
+		delay := backOff.Next()
+		glog.Errorf("Error doing %s for %s, backing off %s: %v", msg, c.url(), delay, err)
 		select {
-		case <-time.After(backOff):
+		case <-time.After(delay):
 		case <-c.quit:
 			return
 		}
-		backOff *= 2
-		if backOff > maxBackOff {
-			backOff = maxBackOff
-		}
 	}
 }
 
@@ -276,14 +669,15 @@ func (c *appClient) closePipeConn(id string) {
 	}
 }
 
-func (c *appClient) pipeConnection(id string, pipe pipeutil.Pipe) (bool, error) {
-	newURL, err := util_url.ParseURL(c.url, pipe.PipeType())
+func (c *appClient) pipeConnection(id string, entry *pipeEntry) (bool, error) {
+	pipe := entry.pipe
+	newURL, err := util_url.ParseURL(c.url(), pipe.PipeType())
 	if err != nil {
 		return false, err
 	}
 	newURLStr := fmt.Sprintf("%s.%s:%s", newURL.String(), pipe.PipeType(), id)
 	headers := http.Header{}
-	conn, resp, err := dialWS(newURLStr, headers)
+	conn, resp, err := dialWS(c.pipeDialer, newURLStr, headers)
 	if resp != nil && resp.StatusCode == http.StatusNotFound {
 		glog.V(2).Info("response with not found")
 		pipe.Close()
@@ -298,16 +692,56 @@ func (c *appClient) pipeConnection(id string, pipe pipeutil.Pipe) (bool, error)
 	defer c.closePipeConn(id)
 
 	_, remote := pipe.Ends()
-	if err := pipe.CopyToWebsocket(remote, conn); err != nil {
+	if err := pipe.CopyToWebsocket(&activeReadWriter{ReadWriter: remote, entry: entry}, conn); err != nil {
 		glog.Errorf("pipe copy to websocket: %v", err)
-		if !IsExpectedWSCloseError(err) {
+		// io.EOF is the end of logs that are not followed, nothing to retry
+		if err != io.EOF && !IsExpectedWSCloseError(err) {
 			return false, err
 		}
+		if err == io.EOF {
+			// tell DevOps service everything was sent, such as a whole download
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(WriteWait))
+		}
 	}
 	pipe.Close()
 	return true, nil
 }
 
+func withSeq(packet *model.Packet, seq uint64) *model.Packet {
+	p := *packet
+	p.Seq = seq
+	return &p
+}
+
+func newAck(command *model.Packet) *model.Packet {
+	return &model.Packet{
+		Key:  command.Key,
+		Type: model.Ack,
+		Seq:  command.Seq,
+	}
+}
+
+type upstreamStatus struct {
+	URL       string `json:"url"`
+	Index     int    `json:"index"`
+	Endpoints int    `json:"endpoints"`
+}
+
+// newUpstreamStatus tells DevOps service which of the endpoints the agent
+// is connected to.
+func newUpstreamStatus(upstream *url.URL, index int, endpoints int) *model.Packet {
+	status, _ := json.Marshal(upstreamStatus{
+		URL:       upstream.String(),
+		Index:     index,
+		Endpoints: endpoints,
+	})
+	return &model.Packet{
+		Key:     "inter:inter",
+		Type:    model.UpstreamStatus,
+		Payload: string(status),
+	}
+}
+
 func newReConnectCommand() *model.Packet {
 	return &model.Packet{
 		Key:  "inter:inter",
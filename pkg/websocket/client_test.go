@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/channel"
+	"github.com/choerodon/choerodon-cluster-agent/pkg/agent/outbox"
+	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -75,7 +80,7 @@ func TestClient(t *testing.T) {
 	}
 
 	serverURL, _ := url.Parse(fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http")))
-	c, err := NewClient(Token("token"), serverURL.String(), crChan)
+	c, err := NewClient(Token("token"), []string{serverURL.String()}, crChan, nil)
 	assert.Nil(t, err, "no error create new client")
 
 	go c.Loop(shutdown, shutdownWg)
@@ -102,3 +107,256 @@ func TestClient(t *testing.T) {
 
 	responseChan <- resp
 }
+
+func TestClientAck(t *testing.T) {
+	crChan := channel.NewCRChannel(10, 10)
+	respOutbox := outbox.NewMemory(outbox.Options{})
+	acked := make(chan struct{})
+
+	router := gin.Default()
+	router.GET("/agent", func(c *gin.Context) {
+		conn, err := clientTestUpgrader.Upgrade(c.Writer, c.Request, nil)
+		assert.Nil(t, err, "no error upgrades")
+		defer conn.Close()
+
+		var status model.Packet
+		assert.Nil(t, conn.ReadJSON(&status), "no error read upstream status")
+		assert.Equal(t, model.UpstreamStatus, status.Type, "upstream status not sent")
+
+		err = conn.WriteJSON(&model.Packet{Key: "env:test", Type: model.StatusSync, Seq: 7})
+		assert.Nil(t, err, "no error write json")
+
+		var ack model.Packet
+		assert.Nil(t, conn.ReadJSON(&ack), "no error read ack")
+		assert.Equal(t, model.Ack, ack.Type, "command not acked")
+		assert.Equal(t, uint64(7), ack.Seq, "bad ack seq")
+
+		var resp model.Packet
+		assert.Nil(t, conn.ReadJSON(&resp), "no error read response")
+		assert.Equal(t, uint64(1), resp.Seq, "response without seq")
+		assert.Equal(t, 1, respOutbox.Len(), "response not kept before ack")
+
+		err = conn.WriteJSON(&model.Packet{Type: model.Ack, Seq: resp.Seq})
+		assert.Nil(t, err, "no error write ack")
+		close(acked)
+		conn.ReadMessage()
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	serverURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http"))
+	c, err := NewClient(Token("token"), []string{serverURL}, crChan, &Options{
+		Outbox:       respOutbox,
+		AckResponses: true,
+	})
+	assert.Nil(t, err, "no error create new client")
+
+	shutdown := make(chan struct{})
+	shutdownWg := &sync.WaitGroup{}
+	shutdownWg.Add(1)
+	go c.Loop(shutdown, shutdownWg)
+
+	<-crChan.CommandChan
+	cmd := <-crChan.CommandChan
+	assert.Equal(t, uint64(7), cmd.Seq, "bad command seq")
+	crChan.ResponseChan <- &model.Packet{Key: cmd.Key, Type: model.StatusSync}
+
+	<-acked
+	for i := 0; i < 100 && respOutbox.Len() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, respOutbox.Len(), "acked response still kept")
+	close(shutdown)
+	shutdownWg.Wait()
+}
+
+func TestClientDeadPeer(t *testing.T) {
+	crChan := channel.NewCRChannel(10, 10)
+	release := make(chan struct{})
+
+	router := gin.Default()
+	router.GET("/agent", func(c *gin.Context) {
+		conn, err := clientTestUpgrader.Upgrade(c.Writer, c.Request, nil)
+		assert.Nil(t, err, "no error upgrades")
+		defer conn.Close()
+		// never read, so pings are not answered
+		<-release
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer close(release)
+
+	serverURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http"))
+	c, err := NewClient(Token("token"), []string{serverURL}, crChan, &Options{
+		PingInterval: 10 * time.Millisecond,
+		PongWait:     50 * time.Millisecond,
+	})
+	assert.Nil(t, err, "no error create new client")
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.(*appClient).connect()
+	}()
+	select {
+	case <-errCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dead peer not detected")
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	b := newBackOff(time.Second, 4*time.Second)
+	for _, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		delay := b.Next()
+		assert.True(t, delay >= max/2 && delay <= max, "delay %s out of [%s, %s]", delay, max/2, max)
+	}
+	b.Reset()
+	assert.True(t, b.Next() <= time.Second, "back off not reset")
+}
+
+func TestClientTokenFile(t *testing.T) {
+	crChan := channel.NewCRChannel(10, 10)
+	auths := make(chan string, 10)
+
+	router := gin.Default()
+	router.GET("/agent", func(c *gin.Context) {
+		auths <- c.Request.Header.Get("Authorization")
+		conn, err := clientTestUpgrader.Upgrade(c.Writer, c.Request, nil)
+		assert.Nil(t, err, "no error upgrades")
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "token")
+	assert.Nil(t, err, "no error create token file")
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("old\n")
+	tokenFile.Close()
+
+	token, err := ReadTokenFile(tokenFile.Name())
+	assert.Nil(t, err, "no error read token file")
+	serverURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http"))
+	c, err := NewClient(token, []string{serverURL}, crChan, &Options{ReconnectBackOff: 10 * time.Millisecond})
+	assert.Nil(t, err, "no error create new client")
+
+	shutdown := make(chan struct{})
+	shutdownWg := &sync.WaitGroup{}
+	shutdownWg.Add(1)
+	go c.Loop(shutdown, shutdownWg)
+	go WatchTokenFile(tokenFile.Name(), 10*time.Millisecond, c, shutdown)
+	assert.Equal(t, "bearer old", <-auths, "bad first token")
+
+	assert.Nil(t, ioutil.WriteFile(tokenFile.Name(), []byte("new"), 0600), "no error rotate token")
+	select {
+	case auth := <-auths:
+		assert.Equal(t, "bearer new", auth, "not reconnected with the new token")
+	case <-time.After(2 * time.Second):
+		t.Fatal("not reconnected after token change")
+	}
+	assert.Equal(t, Token("new"), c.Token(), "bad client token")
+	close(shutdown)
+	shutdownWg.Wait()
+}
+
+func TestClientFailover(t *testing.T) {
+	crChan := channel.NewCRChannel(10, 10)
+	statuses := make(chan upstreamStatus, 1)
+
+	router := gin.Default()
+	router.GET("/agent", func(c *gin.Context) {
+		conn, err := clientTestUpgrader.Upgrade(c.Writer, c.Request, nil)
+		assert.Nil(t, err, "no error upgrades")
+		defer conn.Close()
+		var packet model.Packet
+		assert.Nil(t, conn.ReadJSON(&packet), "no error read upstream status")
+		var status upstreamStatus
+		assert.Nil(t, json.Unmarshal([]byte(packet.Payload), &status), "no error decode upstream status")
+		statuses <- status
+		conn.ReadMessage()
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// nothing listens on the first endpoint
+	down := httptest.NewServer(router)
+	downURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(down.URL, "http"))
+	down.Close()
+
+	serverURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http"))
+	c, err := NewClient(Token("token"), []string{downURL, serverURL}, crChan, &Options{
+		ReconnectBackOff: 10 * time.Millisecond,
+		FailoverAfter:    2,
+	})
+	assert.Nil(t, err, "no error create new client")
+
+	shutdown := make(chan struct{})
+	shutdownWg := &sync.WaitGroup{}
+	shutdownWg.Add(1)
+	go c.Loop(shutdown, shutdownWg)
+	select {
+	case status := <-statuses:
+		assert.Equal(t, serverURL, status.URL, "bad upstream reported")
+		assert.Equal(t, 1, status.Index, "bad upstream index")
+		assert.Equal(t, 2, status.Endpoints, "bad endpoint count")
+	case <-time.After(2 * time.Second):
+		t.Fatal("not failed over to the second endpoint")
+	}
+	close(shutdown)
+	shutdownWg.Wait()
+}
+
+func TestClientPipes(t *testing.T) {
+	crChan := channel.NewCRChannel(10, 10)
+	opened := make(chan string, 10)
+	closed := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := clientTestUpgrader.Upgrade(w, r, nil)
+		assert.Nil(t, err, "no error upgrades")
+		defer conn.Close()
+		opened <- r.URL.Path
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- r.URL.Path
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	serverURL := fmt.Sprintf("ws%s/agent", strings.TrimPrefix(server.URL, "http"))
+	c, err := NewClient(Token("token"), []string{serverURL}, crChan, &Options{
+		MaxPipes:        1,
+		PipeIdleTimeout: 50 * time.Millisecond,
+	})
+	assert.Nil(t, err, "no error create new client")
+
+	p1, err := NewPipe(c, "p1", pipeutil.Exec)
+	assert.Nil(t, err, "no error open pipe")
+	assert.Equal(t, "/exec.exec:p1", <-opened, "bad pipe path")
+	_, err = NewPipe(c, "p2", pipeutil.Exec)
+	assert.NotNil(t, err, "pipe limit not applied")
+
+	assert.True(t, c.ClosePipe("p1"), "open pipe not found")
+	assert.True(t, p1.Closed(), "pipe not closed")
+	assert.Equal(t, "/exec.exec:p1", <-closed, "pipe connection not closed")
+	assert.False(t, c.ClosePipe("p1"), "closed pipe still open")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.(*appClient).closeIdlePipes(stop)
+	p2, err := NewPipe(c, "p2", pipeutil.Exec)
+	assert.Nil(t, err, "no error open pipe after close")
+	<-opened
+	select {
+	case <-closed:
+		assert.True(t, p2.Closed(), "idle pipe not closed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle pipe not closed")
+	}
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"unicode/utf8"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
+)
+
+// fragment splits the payload of a packet into parts of at most size bytes,
+// each sent as a packet of its own. Parts are cut on rune boundaries so that
+// every part stays valid JSON string content.
+func fragment(packet *model.Packet, size int, id uint64) []*model.Packet {
+	if size <= 0 || len(packet.Payload) <= size {
+		return []*model.Packet{packet}
+	}
+	var parts []string
+	payload := packet.Payload
+	for len(payload) > size {
+		end := size
+		for end > 0 && !utf8.RuneStart(payload[end]) {
+			end--
+		}
+		if end == 0 {
+			end = size
+		}
+		parts = append(parts, payload[:end])
+		payload = payload[end:]
+	}
+	parts = append(parts, payload)
+
+	fragments := make([]*model.Packet, len(parts))
+	for i, part := range parts {
+		p := *packet
+		p.Payload = part
+		p.Fragment = &model.Fragment{
+			Id:    id,
+			Index: i,
+			Total: len(parts),
+		}
+		fragments[i] = &p
+	}
+	return fragments
+}
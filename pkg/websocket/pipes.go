@@ -1,8 +1,14 @@
 package websocket
 
 import (
-	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
+	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+
+	pipeutil "github.com/choerodon/choerodon-cluster-agent/pkg/util/pipe"
 )
 
 type pipe struct {
@@ -27,6 +33,12 @@ var NewPipe = func(c Client, id string, pipeType string) (pipeutil.Pipe, error)
 	return newPipe(pipeutil.NewPipe(pipeType), c, id)
 }
 
+// NewTerminalPipe returns an exec pipe that feeds the resize frames it
+// receives to sizes.
+func NewTerminalPipe(c Client, id string, sizes *pipeutil.TerminalSizeQueue) (pipeutil.Pipe, error) {
+	return newPipe(pipeutil.NewTerminalPipe(pipeutil.Exec, sizes), c, id)
+}
+
 func NewPipeFromEnds(local, remote io.ReadWriter, c Client, id string, pipeType string) (pipeutil.Pipe, error) {
 	return newPipe(pipeutil.NewPipeFromEnds(local, remote, pipeType), c, id)
 }
@@ -39,3 +51,93 @@ func (p *pipe) Close() error {
 	}
 	return err2
 }
+
+// pipeEntry is an open pipe and the time data last went through it.
+type pipeEntry struct {
+	pipe       pipeutil.Pipe
+	lastActive int64
+}
+
+func (e *pipeEntry) touch() {
+	atomic.StoreInt64(&e.lastActive, time.Now().UnixNano())
+}
+
+func (e *pipeEntry) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&e.lastActive)))
+}
+
+// activeReadWriter marks its pipe active whenever data goes through.
+type activeReadWriter struct {
+	io.ReadWriter
+	entry *pipeEntry
+}
+
+func (rw *activeReadWriter) Read(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Read(p)
+	if n > 0 {
+		rw.entry.touch()
+	}
+	return n, err
+}
+
+func (rw *activeReadWriter) Write(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Write(p)
+	if n > 0 {
+		rw.entry.touch()
+	}
+	return n, err
+}
+
+func (c *appClient) registerPipe(id string, pipe pipeutil.Pipe) (*pipeEntry, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.hasQuit() {
+		return nil, fmt.Errorf("client stopped")
+	}
+	if _, ok := c.pipes[id]; ok {
+		return nil, fmt.Errorf("pipe %s already open", id)
+	}
+	if c.maxPipes > 0 && len(c.pipes) >= c.maxPipes {
+		return nil, fmt.Errorf("too many pipes open, at most %d are allowed", c.maxPipes)
+	}
+	entry := &pipeEntry{pipe: pipe}
+	entry.touch()
+	c.pipes[id] = entry
+	pipesOpen.Set(float64(len(c.pipes)))
+	return entry, nil
+}
+
+func (c *appClient) unregisterPipe(id string, pipe pipeutil.Pipe) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if entry, ok := c.pipes[id]; ok && entry.pipe == pipe {
+		delete(c.pipes, id)
+		pipesOpen.Set(float64(len(c.pipes)))
+	}
+}
+
+// closeIdlePipes closes the pipes nothing went through for the idle timeout,
+// such as a terminal left open in a browser tab.
+func (c *appClient) closeIdlePipes(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.pipeIdle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			var idle []string
+			c.mtx.Lock()
+			for id, entry := range c.pipes {
+				if entry.idle(now) > c.pipeIdle {
+					idle = append(idle, id)
+				}
+			}
+			c.mtx.Unlock()
+			for _, id := range idle {
+				glog.Infof("Pipe %s idle for more than %s, closing it", id, c.pipeIdle)
+				c.ClosePipe(id)
+			}
+		}
+	}
+}
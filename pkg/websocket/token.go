@@ -2,7 +2,12 @@ package websocket
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
 )
 
 type Token string
@@ -12,3 +17,43 @@ func (t Token) Set(req *http.Request) {
 		req.Header.Set("Authorization", fmt.Sprintf("bearer %s", t))
 	}
 }
+
+// ReadTokenFile reads a token such as a key of a mounted Secret.
+func ReadTokenFile(path string) (Token, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read token file %s: %v", path, err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return Token(token), nil
+}
+
+// WatchTokenFile polls the token file and hands every new token to the
+// client. Polling also catches the symlink swap kubelet does on Secret
+// updates, which file notifications on the path miss.
+func WatchTokenFile(path string, interval time.Duration, c Client, stop <-chan struct{}) {
+	last, _ := ReadTokenFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			token, err := ReadTokenFile(path)
+			if err != nil {
+				glog.Warning(err)
+				continue
+			}
+			if token == last {
+				continue
+			}
+			glog.Infof("token file %s changed", path)
+			last = token
+			c.UpdateToken(token)
+		}
+	}
+}
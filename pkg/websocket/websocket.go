@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,17 +9,30 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-func dial(urlStr string, token Token) (*websocket.Conn, error) {
+func newDialer(compression bool, tlsConfig *tls.Config) *websocket.Dialer {
+	return &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		WriteBufferSize:   102400,
+		EnableCompression: compression,
+		TLSClientConfig:   tlsConfig,
+	}
+}
+
+// newPipeDialer returns the default dialer, talking TLS the same way as the
+// main connection.
+func newPipeDialer(tlsConfig *tls.Config) *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
+	return &dialer
+}
+
+func dial(dialer *websocket.Dialer, urlStr string, token Token) (*websocket.Conn, error) {
 	req, err := http.NewRequest("GET", urlStr, nil)
 	if err != nil {
 		return nil, fmt.Errorf("constructing request %s: %v", urlStr, err)
 	}
 
 	token.Set(req)
-	dialer := &websocket.Dialer{
-		Proxy:           http.ProxyFromEnvironment,
-		WriteBufferSize: 102400,
-	}
 	conn, _, err := dialer.Dial(urlStr, req.Header)
 	if err != nil {
 		return nil, fmt.Errorf("dial error %s: %v", urlStr, err)
@@ -27,8 +41,8 @@ func dial(urlStr string, token Token) (*websocket.Conn, error) {
 	return conn, nil
 }
 
-func dialWS(urlStr string, requestHeader http.Header) (*websocket.Conn, *http.Response, error) {
-	conn, resp, err := websocket.DefaultDialer.Dial(urlStr, requestHeader)
+func dialWS(dialer *websocket.Dialer, urlStr string, requestHeader http.Header) (*websocket.Conn, *http.Response, error) {
+	conn, resp, err := dialer.Dial(urlStr, requestHeader)
 	if err != nil {
 		return nil, resp, fmt.Errorf("dial error %s: %v", urlStr, err)
 	}
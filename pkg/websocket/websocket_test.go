@@ -1,16 +1,22 @@
 package websocket
 
 import (
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/choerodon/choerodon-cluster-agent/pkg/model"
 )
 
 var (
@@ -46,7 +52,7 @@ func TestDial(t *testing.T) {
 	server := httptest.NewServer(websocketTestRouter(t))
 	defer server.Close()
 	serverURL, _ := url.Parse(fmt.Sprintf("ws%s", strings.TrimPrefix(server.URL, "http")))
-	conn, err := dial(serverURL.String(), Token("token"))
+	conn, err := dial(newDialer(false, nil), serverURL.String(), Token("token"))
 	assert.Nil(t, err, "no error websocket dial")
 	defer conn.Close()
 
@@ -67,3 +73,43 @@ func TestDial(t *testing.T) {
 		assert.Equal(t, test.want, string(p), "bad message")
 	}
 }
+
+func TestDialTLS(t *testing.T) {
+	server := httptest.NewTLSServer(websocketTestRouter(t))
+	defer server.Close()
+	serverURL := fmt.Sprintf("wss%s", strings.TrimPrefix(server.URL, "https"))
+
+	caFile, err := ioutil.TempFile("", "ca")
+	assert.Nil(t, err, "no error create CA file")
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	_, err = dial(newDialer(false, nil), serverURL, Token("token"))
+	assert.NotNil(t, err, "unknown CA trusted")
+
+	tlsConfig, err := (&TLSOptions{CAFile: caFile.Name(), ServerName: "example.com"}).Config()
+	assert.Nil(t, err, "no error build TLS config")
+	conn, err := dial(newDialer(false, tlsConfig), serverURL, Token("token"))
+	assert.Nil(t, err, "no error websocket dial with CA")
+	conn.Close()
+
+	_, err = (&TLSOptions{CertFile: caFile.Name()}).Config()
+	assert.NotNil(t, err, "client certificate without key accepted")
+}
+
+func TestFragment(t *testing.T) {
+	packet := &model.Packet{Key: "env:test", Type: model.HelmReleaseGetContent, Payload: "abc"}
+	assert.Equal(t, []*model.Packet{packet}, fragment(packet, 3, 1), "small packet split")
+
+	packet.Payload = "ab编码c"
+	fragments := fragment(packet, 4, 2)
+	assert.Equal(t, 3, len(fragments), "bad fragment count")
+	payload := ""
+	for i, f := range fragments {
+		assert.Equal(t, model.Fragment{Id: 2, Index: i, Total: 3}, *f.Fragment, "bad fragment")
+		assert.True(t, utf8.ValidString(f.Payload), "rune split")
+		payload += f.Payload
+	}
+	assert.Equal(t, packet.Payload, payload, "bad reassembled payload")
+}